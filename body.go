@@ -0,0 +1,160 @@
+package ginrollbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readCloser restores a partially read body while keeping the original Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bufferBody reads up to maxBytes of the request body and puts them back in front of
+// the unread remainder, so the handlers still see the complete body
+func bufferBody(c *gin.Context, maxBytes int) []byte {
+	if c.Request == nil || c.Request.Body == nil || c.Request.Body == http.NoBody || maxBytes <= 0 {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(maxBytes)))
+	c.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), c.Request.Body),
+		Closer: c.Request.Body,
+	}
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// bindBodyBytes caps the body attached to bind errors when WithCaptureBody is not set
+const bindBodyBytes = 4096
+
+// bodyRecorder keeps up to maxBytes of what the handlers read from the request body
+type bodyRecorder struct {
+	io.ReadCloser
+	maxBytes int
+	read     []byte
+}
+
+func (r *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if room := r.maxBytes - len(r.read); room > 0 {
+		r.read = append(r.read, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// recordBody wraps the request body so what the handlers read from it is kept,
+// nothing is read ahead of them
+func recordBody(c *gin.Context, maxBytes int) *bodyRecorder {
+	if c.Request == nil || c.Request.Body == nil || c.Request.Body == http.NoBody || maxBytes <= 0 {
+		return nil
+	}
+
+	recorder := bodyRecorders.Get().(*bodyRecorder)
+	recorder.ReadCloser, recorder.maxBytes = c.Request.Body, maxBytes
+	c.Request.Body = recorder
+	return recorder
+}
+
+// bodyRecorders are reused across requests, with the capacity of what they recorded
+var bodyRecorders = sync.Pool{New: func() interface{} { return new(bodyRecorder) }}
+
+// releaseBody puts the original body back in req and in the current request of c when they
+// still hold recorder, which goes back to its pool
+func releaseBody(recorder *bodyRecorder, req *http.Request, c *gin.Context) {
+	if recorder == nil {
+		return
+	}
+	for _, req := range [...]*http.Request{req, c.Request} {
+		if req != nil && req.Body == recorder {
+			req.Body = recorder.ReadCloser
+		}
+	}
+	recorder.ReadCloser, recorder.read = nil, recorder.read[:0]
+	bodyRecorders.Put(recorder)
+}
+
+// scrubJSONBody masks the values of the keys matched by scrub at any depth of a JSON body.
+// Bodies that are not valid JSON, including truncated ones, are redacted textually
+func scrubJSONBody(body []byte, scrub func(key string) bool) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil || decoder.More() {
+		return redactBody(body, scrub)
+	}
+	scrubbed, err := json.Marshal(scrubJSONValue(doc, scrub))
+	if err != nil {
+		return redactBody(body, scrub)
+	}
+	return scrubbed
+}
+
+var (
+	// jsonPairPattern matches a "key": value pair of a malformed JSON body, the value being a
+	// string, possibly cut off by the size cap, or a bare token
+	jsonPairPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*(?:"|$)|[^,}\]\s]+)`)
+
+	// formPairPattern matches a key=value pair of a form encoded body
+	formPairPattern = regexp.MustCompile(`(^|&)([^=&]+)=([^&]*)`)
+)
+
+// redactBody masks the values of the keys matched by scrub in a body that cannot be parsed,
+// both as "key": value JSON pairs and as key=value form pairs
+func redactBody(body []byte, scrub func(key string) bool) []byte {
+	body = jsonPairPattern.ReplaceAllFunc(body, func(pair []byte) []byte {
+		match := jsonPairPattern.FindSubmatch(pair)
+		if !scrub(string(match[1])) {
+			return pair
+		}
+		return []byte(`"` + string(match[1]) + `"` + string(match[2]) + `"` + scrubbedValue + `"`)
+	})
+	return formPairPattern.ReplaceAllFunc(body, func(pair []byte) []byte {
+		match := formPairPattern.FindSubmatch(pair)
+		key, err := url.QueryUnescape(string(match[2]))
+		if err != nil || !scrub(key) {
+			return pair
+		}
+		return []byte(string(match[1]) + string(match[2]) + "=" + scrubbedValue)
+	})
+}
+
+func scrubJSONValue(value interface{}, scrub func(key string) bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if scrub(key) {
+				v[key] = scrubbedValue
+				continue
+			}
+			v[key] = scrubJSONValue(item, scrub)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = scrubJSONValue(item, scrub)
+		}
+	}
+	return value
+}
+
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package ginrollbar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubJSONBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "nested fields are masked",
+			body:     `{"user":{"name":"gopher","password":"secret"},"id":12345678901234567890}`,
+			expected: `{"id":12345678901234567890,"user":{"name":"gopher","password":"********"}}`,
+		},
+		{
+			name:     "fields inside arrays are masked",
+			body:     `[{"Token":"abc"},{"token":{"nested":true}}]`,
+			expected: `[{"Token":"********"},{"token":"********"}]`,
+		},
+		{
+			name:     "truncated JSON is redacted textually",
+			body:     `{"name":"gopher","password":"sec`,
+			expected: `{"name":"gopher","password":"********"`,
+		},
+		{
+			name:     "malformed JSON is redacted textually",
+			body:     `{"user":"a", "password" : "hunter2", "token": 42,}`,
+			expected: `{"user":"a", "password" : "********", "token": "********",}`,
+		},
+		{
+			name:     "form bodies are redacted",
+			body:     "user=a&password=secret",
+			expected: "user=a&password=********",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(scrubJSONBody([]byte(tt.body), func(key string) bool {
+				return containsFold([]string{"password", "token"}, key)
+			})))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package ginrollbar
+
+import (
+	"sync"
+	"time"
+)
+
+// maxDedupeEntries bounds the number of distinct errors tracked by a deduper
+const maxDedupeEntries = 1000
+
+// allow monkey-patching
+var now = time.Now
+
+type dedupeEntry struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+// deduper collapses identical errors reported within a time window
+type deduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*dedupeEntry
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{
+		window:  window,
+		entries: make(map[string]*dedupeEntry),
+	}
+}
+
+// allow reports whether the error identified by key should be sent and how many
+// occurrences it stands for, including the ones suppressed since it was last sent
+func (d *deduper) allow(key string) (bool, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t := now()
+	entry, ok := d.entries[key]
+	if ok && t.Sub(entry.lastSent) < d.window {
+		entry.suppressed++
+		return false, 0
+	}
+
+	if !ok {
+		if len(d.entries) >= maxDedupeEntries {
+			d.evict(t)
+		}
+		entry = &dedupeEntry{}
+		d.entries[key] = entry
+	}
+	occurrences := entry.suppressed + 1
+	entry.lastSent = t
+	entry.suppressed = 0
+	return true, occurrences
+}
+
+// evict drops the expired entries, or the least recently sent one if none expired
+func (d *deduper) evict(t time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range d.entries {
+		if t.Sub(entry.lastSent) >= d.window {
+			delete(d.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.lastSent.Before(oldest) {
+			oldestKey, oldest = key, entry.lastSent
+		}
+	}
+	if len(d.entries) >= maxDedupeEntries {
+		delete(d.entries, oldestKey)
+	}
+}
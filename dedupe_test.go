@@ -0,0 +1,63 @@
+package ginrollbar
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock replaces now for the duration of the test
+func fakeClock(t *testing.T) *time.Time {
+	t.Helper()
+	origNow := now
+	t.Cleanup(func() { now = origNow })
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	return &clock
+}
+
+func TestDeduperAllow(t *testing.T) {
+	clock := fakeClock(t)
+	d := newDeduper(time.Minute)
+
+	send, occurrences := d.allow("a")
+	assert.True(t, send)
+	assert.Equal(t, 1, occurrences)
+
+	*clock = clock.Add(10 * time.Second)
+	send, _ = d.allow("a")
+	assert.False(t, send, "duplicate inside the window")
+	send, _ = d.allow("a")
+	assert.False(t, send, "duplicate inside the window")
+
+	send, occurrences = d.allow("b")
+	assert.True(t, send, "other keys are not affected")
+	assert.Equal(t, 1, occurrences)
+
+	*clock = clock.Add(time.Minute)
+	send, occurrences = d.allow("a")
+	assert.True(t, send, "window expired")
+	assert.Equal(t, 3, occurrences)
+
+	send, _ = d.allow("a")
+	assert.False(t, send, "window restarted")
+}
+
+func TestDeduperIsBounded(t *testing.T) {
+	clock := fakeClock(t)
+	d := newDeduper(time.Hour)
+
+	for i := range maxDedupeEntries + 10 {
+		*clock = clock.Add(time.Second)
+		d.allow(strconv.Itoa(i))
+	}
+	assert.Len(t, d.entries, maxDedupeEntries)
+	assert.NotContains(t, d.entries, "0", "least recently sent entries are evicted")
+
+	*clock = clock.Add(2 * time.Hour)
+	d.allow("new")
+	assert.Len(t, d.entries, 1, "expired entries are evicted")
+}
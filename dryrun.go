@@ -0,0 +1,59 @@
+package ginrollbar
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DryRunReport is a report recorded instead of being sent to rollbar, see WithDryRun
+type DryRunReport struct {
+	Kind      string // "error" or "panic"
+	Level     string
+	Err       error
+	Method    string
+	Endpoint  string
+	ExtraData map[string]interface{}
+}
+
+// dryRunStore keeps the reports of WithDryRun, it is shared by the copies of the settings
+type dryRunStore struct {
+	mu      sync.Mutex
+	reports []DryRunReport
+}
+
+func (s *dryRunStore) record(report DryRunReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports = append(s.reports, report)
+}
+
+func (s *dryRunStore) list() []DryRunReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.reports)
+}
+
+// recordDryRun records the report instead of sending it
+func (cfg *config) recordDryRun(c *gin.Context, kind, level string, err error, extraData map[string]interface{}) {
+	report := DryRunReport{
+		Kind:      kind,
+		Level:     level,
+		Err:       err,
+		Endpoint:  cfg.endpoint(c),
+		ExtraData: extraData,
+	}
+	if c.Request != nil {
+		report.Method = c.Request.Method
+	}
+	cfg.dryRun.record(report)
+}
+
+// DryRunReports returns the reports recorded so far with WithDryRun, nil without it
+func (m *Middleware) DryRunReports() []DryRunReport {
+	if store := m.config().dryRun; store != nil {
+		return store.list()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package ginrollbar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDryRun(t *testing.T) {
+	t.Run("reports are recorded instead of sent", func(t *testing.T) {
+		rep := captureReports(t)
+		middleware := NewMiddleware(false, false, "", WithDryRun(true))
+		router := newTestRouter(middleware.Handler())
+		router.GET("/users", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/users?page=2", router)
+
+		assert.Empty(t, rep.errors)
+		assert.Empty(t, rep.criticals)
+		reports := middleware.DryRunReports()
+		if assert.Len(t, reports, 2) {
+			assert.Equal(t, "error", reports[0].Kind)
+			assert.Equal(t, rollbar.ERR, reports[0].Level)
+			assert.EqualError(t, reports[0].Err, "test error")
+			assert.Equal(t, "GET", reports[0].Method)
+			assert.Equal(t, "/users?page=2", reports[0].Endpoint)
+			assert.Equal(t, "/users", reports[0].ExtraData["route"])
+
+			assert.Equal(t, "panic", reports[1].Kind)
+			assert.Equal(t, rollbar.CRIT, reports[1].Level)
+			assert.EqualError(t, reports[1].Err, "occurs panic")
+		}
+	})
+
+	t.Run("nothing is recorded without it", func(t *testing.T) {
+		rep := captureReports(t)
+		middleware := NewMiddleware(false, false, "")
+		router := newTestRouter(middleware.Handler())
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.errors, 1)
+		assert.Nil(t, middleware.DryRunReports())
+	})
+}
@@ -0,0 +1,34 @@
+package ginrollbar
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+// allow monkey-patching
+var (
+	RollbarToken = rollbar.Token
+
+	// FallbackWriter receives the reports of WithStderrFallback
+	FallbackWriter io.Writer = os.Stderr
+)
+
+// useFallback reports whether the report should be written to FallbackWriter because
+// rollbar has no token to send it with
+func (cfg *config) useFallback() bool {
+	if !cfg.stderrFallback {
+		return false
+	}
+	if cfg.client != nil {
+		return cfg.client.Token() == ""
+	}
+	return RollbarToken() == ""
+}
+
+// writeFallback writes a one line report to FallbackWriter
+func writeFallback(level string, err error, extraData map[string]interface{}) {
+	_, _ = fmt.Fprintf(FallbackWriter, "ginrollbar: %s: %v %v\n", level, err, extraData)
+}
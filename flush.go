@@ -0,0 +1,36 @@
+package ginrollbar
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rollbar/rollbar-go"
+)
+
+// allow monkey-patching
+var RollbarWait = rollbar.Wait
+
+// ErrFlushTimeout is returned by Flush when the queued items were not sent in time
+var ErrFlushTimeout = errors.New("ginrollbar: timed out waiting for rollbar to flush")
+
+// Flush blocks until every queued rollbar item has been sent, or returns ErrFlushTimeout
+// after timeout. Call it before exiting so reports sent right before shutdown are not lost
+func Flush(timeout time.Duration) error {
+	// read before starting the goroutine, which may outlive a timed out call
+	wait := RollbarWait
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+}
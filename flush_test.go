@@ -0,0 +1,41 @@
+package ginrollbar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		expected error
+	}{
+		{name: "returns once rollbar is flushed", delay: 0, expected: nil},
+		{name: "returns an error when the flush exceeds the timeout", delay: time.Second, expected: ErrFlushTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origWait := RollbarWait
+			t.Cleanup(func() { RollbarWait = origWait })
+
+			release := make(chan struct{})
+			defer close(release)
+			RollbarWait = func() {
+				select {
+				case <-time.After(tt.delay):
+				case <-release:
+				}
+			}
+
+			start := time.Now()
+			err := Flush(50 * time.Millisecond)
+
+			assert.Equal(t, tt.expected, err)
+			assert.Less(t, time.Since(start), 500*time.Millisecond, "Flush should respect the timeout")
+		})
+	}
+}
@@ -1,74 +1,1060 @@
 package ginrollbar
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/rollbar/rollbar-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // allow monkey-patching
+// They are given the stack skip of WithStackSkip as is, the default ones add the frames of
+// the middleware and their own before calling rollbar-go
 var (
-	RollbarCritical = rollbar.Critical
-	RollbarError    = rollbar.Error
+	RollbarCritical = skippingMiddlewareFrames(rollbar.Critical)
+	RollbarError    = skippingMiddlewareFrames(rollbar.Error)
+	RollbarWarning  = skippingMiddlewareFrames(rollbar.Warning)
+	RollbarInfo     = skippingMiddlewareFrames(rollbar.Info)
+	RollbarDebug    = skippingMiddlewareFrames(rollbar.Debug)
+
+	RollbarCriticalWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		rollbar.Critical(append(addStackSkip(interfaces, middlewareFrames), ctx)...)
+	}
+	RollbarErrorWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		rollbar.Error(append(addStackSkip(interfaces, middlewareFrames), ctx)...)
+	}
+)
+
+// skippingMiddlewareFrames wraps a rollbar-go function so the stack skip it is given counts
+// from the function recovering the panic
+func skippingMiddlewareFrames(fn func(...interface{})) func(...interface{}) {
+	return func(interfaces ...interface{}) {
+		fn(addStackSkip(interfaces, middlewareFrames)...)
+	}
+}
+
+// addStackSkip returns interfaces with frames added to the stack skip they hold, if any
+func addStackSkip(interfaces []interface{}, frames int) []interface{} {
+	for i, value := range interfaces {
+		if skip, ok := value.(int); ok {
+			interfaces = slices.Clone(interfaces)
+			interfaces[i] = skip + frames
+			break
+		}
+	}
+	return interfaces
+}
+
+// randFloat64 drives error sampling, tests replace it with a seeded source
+var randFloat64 = rand.Float64 //nolint:gosec // sampling does not need a secure source
+
+// StackKey is the gin context key holding the stack captured when a panic is recovered,
+// see WithPreserveStack
+const StackKey = "ginrollbar.stack"
+
+// scrubbedValue replaces the value of sensitive fields copied into the extra data
+const scrubbedValue = "********"
+
+// scrubFields matches sensitive keys, mirroring the default of rollbar-go which only
+// scrubs the request it is given and not our extra data
+var scrubFields = regexp.MustCompile(`(?i)password|secret|token`)
+
+// renamableKeys are the built-in extra data keys WithKeyNames may rename, the lifted
+// fields read by Transform keep their names
+var renamableKeys = map[string]struct{}{
+	"endpoint":          {},
+	"method":            {},
+	"route":             {},
+	"handler":           {},
+	"status_code":       {},
+	"duration_ms":       {},
+	"body":              {},
+	"request_id":        {},
+	"ip":                {},
+	"query":             {},
+	"headers":           {},
+	"response_headers":  {},
+	"person":            {},
+	"meta":              {},
+	"occurrences":       {},
+	"cause_chain":       {},
+	"stack":             {},
+	"prior_errors":      {},
+	"panic_type":        {},
+	"tags":              {},
+	"timeout":           {},
+	"additional_errors": {},
+	"runtime":           {},
+	"context":           {},
+	"response_bytes":    {},
+	"client_ip":         {},
+	"remote_addr":       {},
+	"proto":             {},
+	"tls":               {},
+	"client_disconnect": {},
+	"trace_id":          {},
+	"span_id":           {},
+	"referer":           {},
+	"origin":            {},
+	"truncated":         {},
+	"error_type":        {},
+	"event_time":        {},
+	"user_agent":        {},
+	"browser":           {},
+	"os":                {},
+	"device":            {},
+	"server_host":       {},
+	"cookie_names":      {},
+}
+
+// serverHost is the hostname reported by WithServerHost, looked up once
+var serverHost = sync.OnceValue(func() string {
+	return resolveHostname(os.Hostname)
+})
+
+// resolveHostname returns the hostname, "unknown" when it cannot be read
+func resolveHostname(hostname func() (string, error)) string {
+	name, err := hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// Frames between the function recovering a panic and the rollbar client, added to the stack
+// skip so the default of 3 starts the reported stack at the recovering function on every path
+const (
+	// reportPanicFrames are reportPanic or ReportPanic, and report
+	reportPanicFrames = 2
+	// rollbarFuncFrames are the package functions of rollbar-go, e.g. rollbar.Critical and
+	// rollbar.Log, in front of the standard client
+	rollbarFuncFrames = 2
+	// defaultFuncFrames is the default monkey-patchable function, e.g. RollbarCritical
+	defaultFuncFrames = 1
+	// middlewareFrames are added by the default monkey-patchable functions
+	middlewareFrames = reportPanicFrames + defaultFuncFrames + rollbarFuncFrames
 )
 
 // Middleware for rollbar panic and error monitoring
 // onlyPanics: if true, only panics will be logged, otherwise errors will be logged
 // printStack: if true, the stack trace will be printed
 // requestIdCtxKey: the key of the request id in the context
-func LogRequests(onlyPanics, printStack bool, requestIdCtxKey string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			// Log errors before handling any panic
-			if !onlyPanics && len(c.Errors) > 0 {
-				extraData := make(map[string]interface{})
-				extraData["endpoint"] = c.Request.RequestURI
-				if requestIdCtxKey != "" {
-					extraData["request_id"] = c.Writer.Header().Get(requestIdCtxKey)
-				}
-				for _, item := range c.Errors {
-					extraData["meta"] = fmt.Sprint(item.Meta)
-					RollbarError(item.Err, c.Request, extraData)
-				}
+// opts: optional settings, see the With* functions
+// Panics are captured from every handler registered after it, including those raised in
+// their own defers once c.Next returns, but not from the middlewares registered before it
+func LogRequests(onlyPanics, printStack bool, requestIdCtxKey string, opts ...Option) gin.HandlerFunc {
+	return NewMiddleware(onlyPanics, printStack, requestIdCtxKey, opts...).Handler()
+}
+
+// handle runs next, the handlers after the middleware, and reports their errors and panics
+// with cfg
+func handle(c *gin.Context, cfg *config, next func(*gin.Context)) {
+	// A second instance in the chain keeps its recovery and hooks but leaves the reporting to
+	// the first one, except for the panics it stops, which the first one never sees
+	duplicate := activeRequests.active(c)
+	var state requestState
+	if duplicate {
+		cfg.warnDuplicate(c)
+		state, _ = activeRequests.lookup(c)
+		state.cfg = cfg
+	} else {
+		// A request without error or panic allocates nothing: the state is registered by value
+		// instead of in the gin context keys, the body recorder is pooled and the extra data is
+		// built only when something is reported
+		req := c.Request
+		state = requestState{cfg: cfg, start: time.Now()}
+		if cfg.captureBodyBytes > 0 {
+			state.body = bufferBody(c, cfg.captureBodyBytes)
+		} else {
+			state.bindBody = recordBody(c, bindBodyBytes)
+		}
+		activeRequests.add(c, req, state)
+		defer releaseBody(state.bindBody, req, c)
+		defer activeRequests.remove(c, req)
+	}
+	if cfg.requestIDGenerator != nil {
+		cfg.ensureRequestID(c)
+	}
+
+	defer func() {
+		r := recover()
+		skip := cfg.skipReport(c)
+		stops := cfg.recover && !cfg.delegateRecovery
+
+		// Log errors before handling any panic, unless the panic report carries them
+		combined := r != nil && cfg.combinePanicContext && cfg.reportPanics
+		if !skip && !duplicate && !combined && !cfg.onlyPanics && len(c.Errors) > 0 && !cfg.skipErrors(c) {
+			cfg.reportErrors(c, &state)
+		}
+
+		// If there's a panic, log it and re-panic
+		// unless this middleware is the terminal recovery handler.
+		if r != nil {
+			var stack string
+			if cfg.captureStack || cfg.preserveStack {
+				stack = string(debug.Stack())
+			}
+			if cfg.preserveStack {
+				c.Set(StackKey, stack)
+			}
+
+			if !skip && cfg.reportPanics && (!duplicate || stops) {
+				cfg.reportPanic(c, &state, r, stack)
+			}
+			if cfg.panicHook != nil {
+				cfg.callPanicHook(c, r)
 			}
 
-			// If there's a panic, recover the panic, log it, and re-panic.
-			if r := recover(); r != nil {
-				if printStack {
-					debug.PrintStack()
-				}
-
-				extraPanicData := make(map[string]interface{})
-				extraPanicData["endpoint"] = c.Request.RequestURI
-				if requestIdCtxKey != "" {
-					extraPanicData["request_id"] = c.Writer.Header().Get(requestIdCtxKey)
-				}
-
-				// From the rollbar-go docs:
-				// Critical reports an item with level `critical`. This function recognizes arguments with the following types:
-				//    *http.Request
-				//    error
-				//    string
-				//    map[string]interface{}
-				//    int
-				// The string and error types are mutually exclusive.
-				// If an error is present then a stack trace is captured. If an int is also present then we skip
-				// that number of stack frames. If the map is present it is used as extra custom data in the
-				// item. If a string is present without an error, then we log a message without a stack
-				// trace. If a request is present we extract as much relevant information from it as we can.
-				RollbarCritical(
-					errors.New(fmt.Sprint(r)),
-					c.Request,
-					3,
-					extraPanicData,
-				)
-				panic(r)
+			if stops {
+				cfg.writePanicResponse(c, r)
+				return
 			}
-		}()
+			panic(r)
+		}
+	}()
+
+	next(c)
+}
+
+// reportPanic reports a recovered panic value, stack is attached when WithCaptureStack is set.
+// It calls report itself, like ReportPanic, so both are reportPanicFrames from rollbar
+func (cfg *config) reportPanic(c *gin.Context, state *requestState, recovered interface{}, stack string) {
+	panicErr, extraPanicData, ok := cfg.panicReport(c, state, recovered, stack)
+	if !ok {
+		return
+	}
+
+	// From the rollbar-go docs:
+	// Critical reports an item with level `critical`. This function recognizes arguments with the following types:
+	//    *http.Request
+	//    error
+	//    string
+	//    map[string]interface{}
+	//    int
+	// The string and error types are mutually exclusive.
+	// If an error is present then a stack trace is captured. If an int is also present then we skip
+	// that number of stack frames. If the map is present it is used as extra custom data in the
+	// item. If a string is present without an error, then we log a message without a stack
+	// trace. If a request is present we extract as much relevant information from it as we can.
+	cfg.report(c, "panic", cfg.panicLevel(c, recovered), panicErr, extraPanicData, cfg.panicStackSkip())
+	cfg.waitIfSynchronous()
+}
+
+// panicReport returns the error and the extra data reported for a recovered panic,
+// false when the before hook cancels the report
+func (cfg *config) panicReport(
+	c *gin.Context,
+	state *requestState,
+	recovered interface{},
+	stack string,
+) (error, map[string]interface{}, bool) {
+	if cfg.printStack {
+		debug.PrintStack()
+	}
+
+	panicErr := cfg.panicError(recovered)
+	extraPanicData := cfg.extraData(c, state)
+	if cfg.captureStack {
+		extraPanicData["stack"] = stack
+	}
+	if runtimeErr, ok := recovered.(runtime.Error); ok {
+		extraPanicData["panic_type"] = fmt.Sprintf("%T", runtimeErr)
+	}
+	if cfg.runtimeStats {
+		extraPanicData["runtime"] = runtimeStats()
+	}
+	if cfg.combinePanicContext && len(c.Errors) > 0 {
+		extraPanicData["prior_errors"] = c.Errors.Errors()
+	}
+	cfg.addErrorData(c, panicErr, extraPanicData)
+	return panicErr, extraPanicData, cfg.callBefore(c, "panic", panicErr, extraPanicData)
+}
+
+// panicStackSkip returns the stack skip passed to rollbar for panics, see WithStackSkip.
+// The monkey-patchable functions get it as configured and add the frames themselves
+func (cfg *config) panicStackSkip() int {
+	if cfg.client != nil {
+		return cfg.stackSkip + reportPanicFrames
+	}
+	return cfg.stackSkip
+}
 
-		c.Next()
+// runtimeStats returns the goroutine count and the memory stats attached to panics
+func runtimeStats() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"alloc":      mem.Alloc,
+		"sys":        mem.Sys,
+	}
+}
+
+// causeChain lists the messages of err and of every error it wraps, outermost first
+func causeChain(err error) []string {
+	var chain []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, err.Error())
+	}
+	return chain
+}
+
+// panicError passes recovered errors through so rollbar sees their type and stack,
+// other values are flattened into a new error, unless WithPanicErrorFunc builds one
+func (cfg *config) panicError(recovered interface{}) error {
+	if cfg.panicErrorFunc != nil {
+		if err := cfg.panicErrorFunc(recovered); err != nil {
+			return err
+		}
+	}
+	if err, ok := recovered.(error); ok {
+		return err
+	}
+	return errors.New(fmt.Sprint(recovered))
+}
+
+// reportErrors sends the gin errors of the request to rollbar
+func (cfg *config) reportErrors(c *gin.Context, state *requestState) {
+	extraData := cfg.extraData(c, state)
+	items := cfg.errorsToReport(c)
+	if cfg.errorFilter != nil {
+		items = slices.DeleteFunc(slices.Clone(items), func(item *gin.Error) bool {
+			return !cfg.errorFilter(item)
+		})
+	}
+	if cfg.reportErrorTypes != 0 {
+		items = slices.DeleteFunc(slices.Clone(items), func(item *gin.Error) bool {
+			return item.Type&cfg.reportErrorTypes == 0
+		})
 	}
+	if cfg.batchErrors && len(items) > 1 {
+		additionalErrors := make([]string, 0, len(items)-1)
+		for _, item := range items[1:] {
+			additionalErrors = append(additionalErrors, item.Error())
+		}
+		extraData["additional_errors"] = additionalErrors
+		items = items[:1]
+	}
+	for _, item := range items {
+		if !cfg.sampled() {
+			continue
+		}
+		errorExtraData := maps.Clone(extraData)
+		if cfg.deduper != nil {
+			send, occurrences := cfg.deduper.allow(cfg.endpoint(c) + "\x00" + item.Error())
+			if !send {
+				continue
+			}
+			if occurrences > 1 {
+				errorExtraData["occurrences"] = occurrences
+			}
+		}
+		cfg.reportError(c, state, item, errorExtraData)
+	}
+	cfg.waitIfSynchronous()
+}
+
+// reportError completes the extra data with the details of a gin error and reports it,
+// bind errors get the body read by the handlers when it was not captured
+func (cfg *config) reportError(c *gin.Context, state *requestState, item *gin.Error, extraData map[string]interface{}) {
+	if item.IsType(gin.ErrorTypeBind) && state != nil && state.bindBody != nil {
+		extraData["body"] = string(cfg.scrubBindBody(state.bindBody.read))
+	}
+	extraData["error_type"] = errorType(item.Type)
+	if clientDisconnected(item.Err) {
+		extraData["client_disconnect"] = true
+	}
+	switch {
+	case cfg.metaSerializer != nil:
+		extraData["meta"] = cfg.metaSerializer(item.Meta)
+	case cfg.structuredMeta:
+		extraData["meta"] = item.Meta
+	default:
+		extraData["meta"] = fmt.Sprint(item.Meta)
+	}
+	cfg.addErrorData(c, item.Err, extraData)
+	if !cfg.callBefore(c, "error", item.Err, extraData) {
+		return
+	}
+	cfg.report(c, "error", cfg.errorLevel(c, item), item.Err, extraData)
+}
+
+// callBefore runs the before hook and merges its extra data, false cancels the report
+func (cfg *config) callBefore(c *gin.Context, kind string, err error, extraData map[string]interface{}) bool {
+	if cfg.before == nil {
+		return true
+	}
+	send, extra := cfg.before(c, kind, err)
+	if send {
+		cfg.mergeExtraData(extraData, extra)
+	}
+	return send
+}
+
+// countReport notifies the report counter of a report handed to rollbar or to the async
+// queue, kind is "error" or "panic"
+func (cfg *config) countReport(kind string) {
+	if cfg.reportCounter != nil {
+		cfg.reportCounter(kind)
+	}
+}
+
+// addErrorData adds the extra data specific to the reported error
+func (cfg *config) addErrorData(c *gin.Context, err error, extraData map[string]interface{}) {
+	if cfg.fingerprintFunc != nil {
+		if fingerprint := cfg.fingerprintFunc(c, err); fingerprint != "" {
+			extraData["fingerprint"] = fingerprint
+		}
+	}
+	if cfg.unwrapErrors {
+		extraData["cause_chain"] = causeChain(err)
+	}
+	if cfg.titleFunc != nil {
+		if title := cfg.titleFunc(c, err); title != "" {
+			extraData["title"] = title
+		}
+	}
+}
+
+// writePanicResponse answers the client after a panic was swallowed in recover mode
+func (cfg *config) writePanicResponse(c *gin.Context, recovered interface{}) {
+	status := cfg.panicStatus
+	if cfg.panicStatusFunc != nil {
+		if code := cfg.panicStatusFunc(recovered); code != 0 {
+			status = code
+		}
+	}
+	if cfg.panicBody == nil {
+		c.AbortWithStatus(status)
+		return
+	}
+	c.AbortWithStatusJSON(status, cfg.panicBody)
+}
+
+// waitIfSynchronous blocks until rollbar has sent the queued items in synchronous mode
+func (cfg *config) waitIfSynchronous() {
+	if !cfg.synchronous {
+		return
+	}
+	if cfg.client != nil {
+		cfg.client.Wait()
+		return
+	}
+	RollbarWait()
+}
+
+// skipReport reports whether nothing should be sent to rollbar for this request
+func (cfg *config) skipReport(c *gin.Context) bool {
+	if !cfg.enabled {
+		return true
+	}
+	if _, ignored := cfg.ignoredPaths[c.FullPath()]; ignored {
+		return true
+	}
+	_, ignored := cfg.ignoredStatusCodes[c.Writer.Status()]
+	return ignored
+}
+
+// errorsToReport returns the gin errors considered for reporting
+func (cfg *config) errorsToReport(c *gin.Context) []*gin.Error {
+	if cfg.lastErrorOnly {
+		return c.Errors[len(c.Errors)-1:]
+	}
+	return c.Errors
+}
+
+// sampled reports whether an error should be sent according to the sample rate
+func (cfg *config) sampled() bool {
+	return cfg.sampleRate >= 1 || randFloat64() < cfg.sampleRate
+}
+
+// report sends an occurrence of kind, "error" or "panic", at level as (err, request, args...,
+// extraData), without the request when there is none, then to the additional reporter if any.
+// A panic of the rollbar call is logged and never breaks the request
+func (cfg *config) report(
+	c *gin.Context,
+	kind string,
+	level string,
+	err error,
+	extraData map[string]interface{},
+	args ...interface{},
+) {
+	if cfg.maxExtraDataBytes > 0 {
+		extraData = truncateExtraData(extraData, cfg.maxExtraDataBytes)
+	}
+	extraData = cfg.renameKeys(extraData)
+	req := cfg.request(c)
+	if cfg.additionalReporter != nil {
+		defer cfg.reportAdditional(err, req, extraData)
+	}
+	defer func() {
+		if r := recover(); r != nil && cfg.logger != nil {
+			cfg.logger.Errorf("ginrollbar: reporting %s on %s failed: %v", level, cfg.endpoint(c), r)
+		}
+	}()
+	if cfg.logger != nil || cfg.slogger != nil {
+		logData := selectFields(extraData, cfg.loggerFields)
+		if cfg.logger != nil {
+			fields := formatFields(cfg.loggerFields, logData)
+			cfg.logger.Errorf("ginrollbar: reporting %s on %s: %v%s", level, cfg.endpoint(c), err, fields)
+		}
+		cfg.logSlog(c, kind, level, err, logData)
+	}
+	if cfg.rollbarFields != nil {
+		extraData = selectFields(extraData, slices.Concat(cfg.rollbarFields, liftedFields))
+	}
+	if cfg.dryRun != nil {
+		cfg.recordDryRun(c, kind, level, err, extraData)
+		return
+	}
+	if cfg.suppressInTestMode && gin.Mode() == gin.TestMode {
+		return
+	}
+	if cfg.useFallback() {
+		writeFallback(level, err, extraData)
+		return
+	}
+
+	interfaces := make([]interface{}, 0, len(args)+3)
+	interfaces = append(interfaces, err)
+	if req != nil {
+		interfaces = append(interfaces, req)
+	}
+	interfaces = append(interfaces, args...)
+	interfaces = append(interfaces, extraData)
+	forwarded := true
+	switch {
+	case cfg.queue != nil:
+		forwarded = cfg.queue.enqueue(cfg.sender(c, level, interfaces))
+	case cfg.client != nil:
+		if cfg.contextPropagation {
+			interfaces = append(interfaces, requestContext(c))
+		}
+		cfg.client.Log(level, interfaces...)
+	case cfg.contextPropagation:
+		if fn := reportWithContextFunc(level); fn != nil {
+			fn(requestContext(c), interfaces...)
+		} else {
+			reportFunc(level)(append(interfaces, requestContext(c))...)
+		}
+	default:
+		reportFunc(level)(interfaces...)
+	}
+	if !forwarded {
+		return
+	}
+	cfg.countReport(kind)
+	if cfg.afterReport != nil {
+		cfg.callAfterReport(c, kind, err)
+	}
+}
+
+// sender returns the rollbar call of report for the async queue, everything it needs from
+// the gin context is resolved beforehand since the context is reused after the request
+func (cfg *config) sender(c *gin.Context, level string, interfaces []interface{}) func() {
+	if cfg.client != nil {
+		client := cfg.client
+		if cfg.contextPropagation {
+			interfaces = append(interfaces, requestContext(c))
+		}
+		return func() { client.Log(level, interfaces...) }
+	}
+	if cfg.contextPropagation {
+		ctx := requestContext(c)
+		if fn := reportWithContextFunc(level); fn != nil {
+			return func() { fn(ctx, interfaces...) }
+		}
+		interfaces = append(interfaces, ctx)
+	}
+	fn := reportFunc(level)
+	return func() { fn(interfaces...) }
+}
+
+// timedOut reports whether the request context was cancelled or its deadline exceeded
+func timedOut(c *gin.Context) bool {
+	if c.Request == nil {
+		return false
+	}
+	err := c.Request.Context().Err()
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// errorTypeNames decode the flags of a gin error type
+var errorTypeNames = []struct {
+	errorType gin.ErrorType
+	name      string
+}{
+	{gin.ErrorTypeBind, "bind"},
+	{gin.ErrorTypeRender, "render"},
+	{gin.ErrorTypePrivate, "private"},
+	{gin.ErrorTypePublic, "public"},
+}
+
+// errorType returns the flags of a gin error type joined with "|", e.g. "bind|private",
+// or its number when it has none of the known flags
+func errorType(errorType gin.ErrorType) string {
+	if errorType == gin.ErrorTypeAny {
+		return "any"
+	}
+	var names []string
+	for _, flag := range errorTypeNames {
+		if errorType&flag.errorType != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	if len(names) == 0 {
+		return strconv.FormatUint(uint64(errorType), 10)
+	}
+	return strings.Join(names, "|")
+}
+
+// clientDisconnected reports whether err comes from the client closing the connection,
+// which is not a bug of the application
+func clientDisconnected(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed)
+}
+
+// requestContext returns the context of the request, or the background one without request
+func requestContext(c *gin.Context) context.Context {
+	if c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+// reportAdditional calls the additional reporter, a panic in it never breaks the request
+func (cfg *config) reportAdditional(err error, req *http.Request, extraData map[string]interface{}) {
+	defer func() {
+		if r := recover(); r != nil && cfg.logger != nil {
+			cfg.logger.Errorf("ginrollbar: additional reporter panicked: %v", r)
+		}
+	}()
+	cfg.additionalReporter(err, req, extraData)
+}
+
+// renameKeys returns extraData with the built-in keys renamed by WithKeyNames,
+// a renamed key wins over custom data using the same name
+func (cfg *config) renameKeys(extraData map[string]interface{}) map[string]interface{} {
+	if len(cfg.keyNames) == 0 {
+		return extraData
+	}
+	renamed := make(map[string]interface{}, len(extraData))
+	for key, value := range extraData {
+		if _, ok := cfg.keyNames[key]; !ok {
+			renamed[key] = value
+		}
+	}
+	for key, name := range cfg.keyNames {
+		if value, ok := extraData[key]; ok {
+			renamed[name] = value
+		}
+	}
+	return renamed
+}
+
+// selectFields returns the entries of extraData under the given keys
+func selectFields(extraData map[string]interface{}, keys []string) map[string]interface{} {
+	selected := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := extraData[key]; ok {
+			selected[key] = value
+		}
+	}
+	return selected
+}
+
+// warnDuplicate logs once that the middleware is registered twice in the chain of a request
+func (cfg *config) warnDuplicate(c *gin.Context) {
+	if cfg.logger == nil {
+		return
+	}
+	cfg.duplicateWarning.Do(func() {
+		cfg.logger.Errorf("ginrollbar: middleware registered twice on %s, only the first one reports", cfg.endpoint(c))
+	})
+}
+
+// callAfterReport calls the after report hook, a panic in it never breaks the request
+func (cfg *config) callAfterReport(c *gin.Context, kind string, err error) {
+	defer func() {
+		if r := recover(); r != nil && cfg.logger != nil {
+			cfg.logger.Errorf("ginrollbar: after report hook panicked: %v", r)
+		}
+	}()
+	cfg.afterReport(c, kind, err)
+}
+
+// callPanicHook calls the panic hook, a panic in it never replaces the original one
+func (cfg *config) callPanicHook(c *gin.Context, recovered interface{}) {
+	defer func() {
+		if r := recover(); r != nil && cfg.logger != nil {
+			cfg.logger.Errorf("ginrollbar: panic hook panicked: %v", r)
+		}
+	}()
+	cfg.panicHook(c, recovered)
+}
+
+// skipErrors reports whether the gin errors of this request should not be sent,
+// these conditions never apply to panics
+func (cfg *config) skipErrors(c *gin.Context) bool {
+	if cfg.shutdownGuard != nil && cfg.shutdownGuard() {
+		return true
+	}
+	if status := c.Writer.Status(); cfg.skipSuccessfulResponses && status >= 200 && status < 300 {
+		return true
+	}
+	return c.Writer.Status() < cfg.minStatusCode
+}
+
+// reportFunc returns the monkey-patchable rollbar function for the given level,
+// unknown levels are reported as errors
+func reportFunc(level string) func(...interface{}) {
+	switch level {
+	case rollbar.CRIT:
+		return RollbarCritical
+	case rollbar.WARN:
+		return RollbarWarning
+	case rollbar.INFO:
+		return RollbarInfo
+	case rollbar.DEBUG:
+		return RollbarDebug
+	default:
+		return RollbarError
+	}
+}
+
+// reportWithContextFunc returns the monkey-patchable context-aware rollbar function
+// for the given level, or nil for the levels without one, whose function of reportFunc
+// gets the context appended to its arguments
+func reportWithContextFunc(level string) func(context.Context, ...interface{}) {
+	switch level {
+	case rollbar.CRIT:
+		return RollbarCriticalWithContext
+	case rollbar.WARN, rollbar.INFO, rollbar.DEBUG:
+		return nil
+	default:
+		return RollbarErrorWithContext
+	}
+}
+
+// panicLevel returns the rollbar level used to report a recovered panic
+func (cfg *config) panicLevel(c *gin.Context, recovered interface{}) string {
+	if cfg.timeoutLevel != "" && timedOut(c) {
+		return cfg.timeoutLevel
+	}
+	if cfg.panicLevelFunc != nil {
+		if level := cfg.panicLevelFunc(recovered); level != "" {
+			return level
+		}
+	}
+	return rollbar.CRIT
+}
+
+// errorLevel returns the rollbar level used to report a gin error
+func (cfg *config) errorLevel(c *gin.Context, item *gin.Error) string {
+	if cfg.timeoutLevel != "" && timedOut(c) {
+		return cfg.timeoutLevel
+	}
+	if clientDisconnected(item.Err) {
+		return cfg.clientDisconnectLevel
+	}
+	if cfg.errorLevelFunc != nil {
+		// "" and unknown levels are reported as errors, rollbar would drop them
+		switch level := cfg.errorLevelFunc(item); level {
+		case rollbar.CRIT, rollbar.ERR, rollbar.WARN, rollbar.INFO, rollbar.DEBUG:
+			return level
+		default:
+			return rollbar.ERR
+		}
+	}
+	if cfg.statusLevelFunc != nil {
+		if level := cfg.statusLevelFunc(c.Writer.Status()); level != "" {
+			return level
+		}
+	}
+	if cfg.privateErrorsAsInfo && item.Type == gin.ErrorTypePrivate {
+		return rollbar.INFO
+	}
+	return rollbar.ERR
+}
+
+// request returns the request to attach to the report, cloned with its
+// scrubbed headers masked so the original is never mutated, or nil with WithoutRequest
+func (cfg *config) request(c *gin.Context) *http.Request {
+	if cfg.withoutRequest {
+		return nil
+	}
+	if len(cfg.scrubbedHeaders) == 0 || c.Request == nil {
+		return c.Request
+	}
+	req := c.Request.Clone(c.Request.Context())
+	for _, name := range cfg.scrubbedHeaders {
+		if _, ok := req.Header[name]; ok {
+			req.Header[name] = []string{scrubbedValue}
+		}
+	}
+	return req
+}
+
+// scrubBody masks the configured fields of a captured JSON body
+func (cfg *config) scrubBody(body []byte) []byte {
+	if len(cfg.scrubbedBodyFields) == 0 {
+		return body
+	}
+	return scrubJSONBody(body, cfg.scrubBodyField)
+}
+
+// scrubBindBody masks the body attached to bind errors, which is captured without being asked
+// for: the configured fields, or the keys matching scrubFields when there are none
+func (cfg *config) scrubBindBody(body []byte) []byte {
+	if len(cfg.scrubbedBodyFields) == 0 {
+		return scrubJSONBody(body, scrubFields.MatchString)
+	}
+	return scrubJSONBody(body, cfg.scrubBodyField)
+}
+
+func (cfg *config) scrubBodyField(key string) bool {
+	return containsFold(cfg.scrubbedBodyFields, key)
+}
+
+// extraData builds the custom data shared by error and panic reports.
+// status_code reflects the response status at the time of reporting: for panics
+// this is captured before any outer recovery writes its own status.
+// state is what the middleware captured before calling the handlers, it may be nil
+func (cfg *config) extraData(c *gin.Context, state *requestState) map[string]interface{} {
+	extraData := make(map[string]interface{})
+	extraData["endpoint"] = cfg.endpoint(c)
+	if c.Request != nil {
+		extraData["method"] = c.Request.Method
+	}
+	if cfg.wrappedHandler != "" {
+		// the gin route and handler of WrapHandler are its own, net/http has no route
+		extraData["handler"] = cfg.wrappedHandler
+	} else {
+		extraData["route"] = c.FullPath()
+		extraData["handler"] = c.HandlerName()
+	}
+	extraData["status_code"] = c.Writer.Status()
+	extraData["response_bytes"] = max(c.Writer.Size(), 0)
+	extraData["event_time"] = now().UTC().Format(time.RFC3339)
+	if state != nil {
+		extraData["duration_ms"] = float64(time.Since(state.start)) / float64(time.Millisecond)
+		if state.body != nil {
+			extraData["body"] = string(cfg.scrubBody(state.body))
+		}
+	}
+	if cfg.requestIdCtxKey != "" {
+		extraData["request_id"] = cfg.requestID(c)
+	}
+	if cfg.environment != "" {
+		extraData["environment"] = cfg.environment
+	}
+	if cfg.codeVersion != "" {
+		extraData["code_version"] = cfg.codeVersion
+	}
+	if timedOut(c) {
+		extraData["timeout"] = true
+	}
+	if len(cfg.tags) > 0 {
+		extraData["tags"] = cfg.tags
+	}
+	if cfg.clientIP && c.Request != nil {
+		extraData["ip"] = cfg.resolveClientIP(c)
+	}
+	if cfg.remoteAddr && c.Request != nil {
+		extraData["client_ip"] = cfg.resolveClientIP(c)
+		extraData["remote_addr"] = c.Request.RemoteAddr
+	}
+	if cfg.protocolInfo && c.Request != nil {
+		extraData["proto"] = c.Request.Proto
+		extraData["tls"] = c.Request.TLS != nil
+	}
+	if cfg.traceContext && c.Request != nil {
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			extraData["trace_id"] = span.TraceID().String()
+			extraData["span_id"] = span.SpanID().String()
+		}
+	}
+	if cfg.refererOrigin && c.Request != nil {
+		if referer := c.GetHeader("Referer"); referer != "" {
+			extraData["referer"] = referer
+		}
+		if origin := c.GetHeader("Origin"); origin != "" {
+			extraData["origin"] = origin
+		}
+	}
+	if cfg.serverHost {
+		extraData["server_host"] = serverHost()
+	}
+	if cfg.userAgentParsing && c.Request != nil {
+		if ua := c.Request.UserAgent(); ua != "" {
+			extraData["user_agent"] = ua
+			extraData["browser"], extraData["os"], extraData["device"] = parseUserAgent(ua)
+		}
+	}
+	if cfg.captureQueryParams && c.Request != nil {
+		extraData["query"] = queryParams(c)
+	}
+	if len(cfg.capturedHeaders) > 0 && c.Request != nil {
+		extraData["headers"] = cfg.headers(c)
+	}
+	if cfg.cookieNames && c.Request != nil {
+		if cookies := c.Request.Cookies(); len(cookies) > 0 {
+			names := make([]string, 0, len(cookies))
+			for _, cookie := range cookies {
+				names = append(names, cookie.Name)
+			}
+			extraData["cookie_names"] = names
+		}
+	}
+	if len(cfg.capturedResponseHeaders) > 0 {
+		extraData["response_headers"] = cfg.responseHeaders(c)
+	}
+	if len(cfg.contextKeys) > 0 {
+		extraData["context"] = cfg.contextValues(c)
+	}
+	if cfg.personFunc != nil {
+		if id, username, email := cfg.personFunc(c); id != "" {
+			extraData["person"] = map[string]string{
+				"id":       id,
+				"username": username,
+				"email":    email,
+			}
+		}
+	}
+	if cfg.extraDataFunc != nil {
+		cfg.mergeExtraData(extraData, cfg.extraDataFunc(c))
+	}
+	return extraData
+}
+
+// mergeExtraData adds the custom keys to extraData, the built-in keys are kept unless
+// WithAllowOverrideReserved is set
+func (cfg *config) mergeExtraData(extraData, custom map[string]interface{}) {
+	for key, value := range custom {
+		if _, reserved := extraData[key]; !reserved || cfg.allowOverrideReserved {
+			extraData[key] = value
+		}
+	}
+}
+
+// headers copies the captured request headers that are present, masking the scrubbed ones
+func (cfg *config) headers(c *gin.Context) map[string]string {
+	headers := make(map[string]string, len(cfg.capturedHeaders))
+	for _, name := range cfg.capturedHeaders {
+		if _, ok := c.Request.Header[name]; !ok {
+			continue
+		}
+		if slices.Contains(cfg.scrubbedHeaders, name) {
+			headers[name] = scrubbedValue
+			continue
+		}
+		headers[name] = c.Request.Header.Get(name)
+	}
+	return headers
+}
+
+// responseHeaders copies the captured response headers that are present
+func (cfg *config) responseHeaders(c *gin.Context) map[string]string {
+	header := responseHeader(c)
+	headers := make(map[string]string, len(cfg.capturedResponseHeaders))
+	for _, name := range cfg.capturedResponseHeaders {
+		if _, ok := header[name]; ok {
+			headers[name] = header.Get(name)
+		}
+	}
+	return headers
+}
+
+// contextValues copies the captured gin context keys that are set, values that cannot be
+// serialized to JSON are stringified
+func (cfg *config) contextValues(c *gin.Context) map[string]interface{} {
+	values := make(map[string]interface{}, len(cfg.contextKeys))
+	for _, key := range cfg.contextKeys {
+		value, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		if _, err := json.Marshal(value); err != nil {
+			value = fmt.Sprint(value)
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// queryParams flattens the query string, joining repeated values with commas
+func queryParams(c *gin.Context) map[string]string {
+	query := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if scrubFields.MatchString(key) {
+			query[key] = scrubbedValue
+			continue
+		}
+		query[key] = strings.Join(values, ",")
+	}
+	return query
+}
+
+// endpoint returns the request URI, or its path with WithStripQuery, empty when the context
+// has no request
+func (cfg *config) endpoint(c *gin.Context) string {
+	if c.Request == nil {
+		return ""
+	}
+	if cfg.stripQuery {
+		return c.Request.URL.Path
+	}
+	return c.Request.RequestURI
+}
+
+// requestID returns the request id, preferring the gin context keys when enabled
+func (cfg *config) requestID(c *gin.Context) string {
+	if cfg.requestIDFromContext {
+		if v, ok := c.Get(cfg.requestIdCtxKey); ok && v != nil {
+			if id := fmt.Sprint(v); id != "" {
+				return id
+			}
+		}
+	}
+	return responseHeader(c).Get(cfg.requestIdCtxKey)
+}
+
+// ensureRequestID generates the request id when none was set before the middleware, it is
+// stored in the gin context keys and the response header named after requestIdCtxKey
+func (cfg *config) ensureRequestID(c *gin.Context) {
+	if cfg.requestIdCtxKey == "" || cfg.requestID(c) != "" {
+		return
+	}
+	id := cfg.requestIDGenerator()
+	c.Set(cfg.requestIdCtxKey, id)
+	c.Header(cfg.requestIdCtxKey, id)
+}
+
+// responseHeader returns the response headers, or none for a context returned by c.Copy()
+// whose writer is detached from the response
+func responseHeader(c *gin.Context) (header http.Header) {
+	defer func() {
+		if recover() != nil {
+			header = http.Header{}
+		}
+	}()
+	return c.Writer.Header()
 }
@@ -1,13 +1,21 @@
 package ginrollbar
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -219,3 +227,724 @@ func performRequest(method, target string, router *gin.Engine) *httptest.Respons
 	router.ServeHTTP(w, r)
 	return w
 }
+
+// reports records the arguments passed to the patched rollbar functions
+type reports struct {
+	errors    [][]interface{}
+	criticals [][]interface{}
+	warnings  [][]interface{}
+	infos     [][]interface{}
+	debugs    [][]interface{}
+}
+
+func captureReports(t *testing.T) *reports {
+	t.Helper()
+	origCritical, origError := RollbarCritical, RollbarError
+	origWarning, origInfo, origDebug := RollbarWarning, RollbarInfo, RollbarDebug
+	origCriticalWithContext, origErrorWithContext := RollbarCriticalWithContext, RollbarErrorWithContext
+	t.Cleanup(func() {
+		RollbarCritical, RollbarError = origCritical, origError
+		RollbarWarning, RollbarInfo, RollbarDebug = origWarning, origInfo, origDebug
+		RollbarCriticalWithContext, RollbarErrorWithContext = origCriticalWithContext, origErrorWithContext
+	})
+
+	rep := &reports{}
+	RollbarCritical = func(interfaces ...interface{}) {
+		rep.criticals = append(rep.criticals, interfaces)
+	}
+	RollbarError = func(interfaces ...interface{}) {
+		rep.errors = append(rep.errors, interfaces)
+	}
+	RollbarWarning = func(interfaces ...interface{}) {
+		rep.warnings = append(rep.warnings, interfaces)
+	}
+	RollbarInfo = func(interfaces ...interface{}) {
+		rep.infos = append(rep.infos, interfaces)
+	}
+	RollbarDebug = func(interfaces ...interface{}) {
+		rep.debugs = append(rep.debugs, interfaces)
+	}
+	RollbarCriticalWithContext = func(_ context.Context, interfaces ...interface{}) {
+		rep.criticals = append(rep.criticals, interfaces)
+	}
+	RollbarErrorWithContext = func(_ context.Context, interfaces ...interface{}) {
+		rep.errors = append(rep.errors, interfaces)
+	}
+	return rep
+}
+
+func extraDataOf(t *testing.T, interfaces []interface{}) map[string]interface{} {
+	t.Helper()
+	for _, item := range interfaces {
+		if extraData, ok := item.(map[string]interface{}); ok {
+			return extraData
+		}
+	}
+	t.Fatal("no map[string]interface{} among the rollbar arguments")
+	return nil
+}
+
+func newTestRouter(middleware gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	})
+	router.Use(middleware)
+	return router
+}
+
+func TestStatusCodeInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/error", func(c *gin.Context) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/error", router)
+	performRequest("GET", "/panic", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Equal(t, http.StatusBadRequest, extraDataOf(t, rep.errors[0])["status_code"])
+	assert.Len(t, rep.criticals, 1)
+	// the status is captured before the outer recovery rewrites it to 500
+	assert.Equal(t, http.StatusAccepted, extraDataOf(t, rep.criticals[0])["status_code"])
+}
+
+func TestDurationInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/", func(c *gin.Context) {
+		time.Sleep(5 * time.Millisecond)
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		duration, ok := extraDataOf(t, rep.errors[0])["duration_ms"].(float64)
+		assert.True(t, ok, "duration_ms should be a float64")
+		assert.GreaterOrEqual(t, duration, 5.0)
+	}
+	if assert.Len(t, rep.criticals, 1) {
+		duration, ok := extraDataOf(t, rep.criticals[0])["duration_ms"].(float64)
+		assert.True(t, ok, "duration_ms should be a float64")
+		assert.GreaterOrEqual(t, duration, 5.0)
+	}
+}
+
+func TestEventTimeInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	before := time.Now().Truncate(time.Second)
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) && assert.Len(t, rep.criticals, 1) {
+		for _, interfaces := range [][]interface{}{rep.errors[0], rep.criticals[0]} {
+			eventTime, ok := extraDataOf(t, interfaces)["event_time"].(string)
+			if assert.True(t, ok, "event_time should be a string") {
+				parsed, err := time.Parse(time.RFC3339, eventTime)
+				assert.NoError(t, err)
+				assert.Equal(t, time.UTC, parsed.Location())
+				assert.False(t, parsed.Before(before))
+			}
+		}
+	}
+}
+
+func TestRouteInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/users/:id", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/users/42", router)
+
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		extraData := extraDataOf(t, interfaces)
+		assert.Equal(t, "/users/:id", extraData["route"])
+		assert.Equal(t, "/users/42", extraData["endpoint"])
+	}
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+}
+
+func TestMethodInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.POST("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("POST", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Equal(t, "POST", extraDataOf(t, rep.errors[0])["method"])
+	}
+	if assert.Len(t, rep.criticals, 1) {
+		assert.Equal(t, "POST", extraDataOf(t, rep.criticals[0])["method"])
+	}
+}
+
+func namedHandler(c *gin.Context) {
+	_ = c.Error(errors.New("test error"))
+	panic("occurs panic")
+}
+
+func TestHandlerInExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/", namedHandler)
+
+	performRequest("GET", "/", router)
+
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		assert.Equal(t, "github.com/neiybor/ginrollbar/v2.namedHandler", extraDataOf(t, interfaces)["handler"])
+	}
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+}
+
+func TestResponseHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Header("X-Test", "value")
+
+	assert.Equal(t, "value", responseHeader(c).Get("X-Test"))
+	assert.Equal(t, http.Header{}, responseHeader(c.Copy()))
+}
+
+type testPanicError struct {
+	code int
+}
+
+func (e *testPanicError) Error() string {
+	return fmt.Sprintf("panic error %d", e.code)
+}
+
+func TestPanicWithErrorValue(t *testing.T) {
+	rep := captureReports(t)
+	panicErr := &testPanicError{code: 42}
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.GET("/", func(c *gin.Context) {
+		panic(panicErr)
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.criticals, 1) {
+		assert.Same(t, panicErr, rep.criticals[0][0])
+	}
+}
+
+func TestNilRequest(t *testing.T) {
+	rep := captureReports(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := &recordingHandler{}
+	var secondaryPanic interface{}
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			secondaryPanic = recover()
+		}()
+		c.Next()
+	})
+	router.Use(func(c *gin.Context) {
+		c.Request = nil
+		c.Next()
+	})
+	router.Use(LogRequests(false, false, "request_id",
+		WithClientIP(true),
+		WithCaptureQueryParams(true),
+		WithCapturedHeaders("User-Agent"),
+		WithScrubbedHeaders(),
+		WithCaptureBody(1024),
+		WithContextPropagation(true),
+		WithDedupeWindow(time.Minute),
+		WithSlog(slog.New(handler)),
+	))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Equal(t, "occurs panic", secondaryPanic, "the original panic should be re-panicked")
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	assert.Len(t, handler.records, 2)
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		assert.Equal(t, "", extraDataOf(t, interfaces)["endpoint"])
+	}
+}
+
+func newNoErrorRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.Use(middlewares...)
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body) //nolint:errcheck
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// noErrorRequests are served again and again, the body of the POST is rewound before each
+var noErrorRequests = []struct {
+	name   string
+	method string
+	body   string
+}{
+	{"GET", "GET", ""},
+	{"POST with body", "POST", `{"name":"test","password":"secret"}`},
+}
+
+// serveNoError returns a function serving the request again with the same writer and body
+func serveNoError(router *gin.Engine, method, body string) func() {
+	reader := strings.NewReader(body)
+	req := httptest.NewRequest(method, "/", nil)
+	if body != "" {
+		req.Body = io.NopCloser(reader)
+	}
+	w := httptest.NewRecorder()
+	return func() {
+		reader.Seek(0, io.SeekStart) //nolint:errcheck
+		router.ServeHTTP(w, req)
+	}
+}
+
+func TestLogRequestsNoErrorAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	for _, tt := range noErrorRequests {
+		t.Run(tt.name, func(t *testing.T) {
+			without := testing.AllocsPerRun(100, serveNoError(newNoErrorRouter(), tt.method, tt.body))
+			with := testing.AllocsPerRun(100, serveNoError(newNoErrorRouter(LogRequests(false, false, "")), tt.method, tt.body))
+
+			assert.Equal(t, without, with, "allocations added by the middleware")
+		})
+	}
+}
+
+func BenchmarkLogRequestsNoError(b *testing.B) {
+	for _, tt := range noErrorRequests {
+		b.Run(tt.name, func(b *testing.B) {
+			serve := serveNoError(newNoErrorRouter(LogRequests(false, false, "")), tt.method, tt.body)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				serve()
+			}
+		})
+	}
+}
+
+func TestBindErrorBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		errorType    gin.ErrorType
+		expectedBody interface{}
+	}{
+		{name: "bind errors get the body", errorType: gin.ErrorTypeBind, expectedBody: `{"name": `},
+		{name: "other errors do not", errorType: gin.ErrorTypePrivate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, ""))
+			router.POST("/", func(c *gin.Context) {
+				var payload struct {
+					Name string `json:"name"`
+				}
+				if err := c.ShouldBindJSON(&payload); err != nil {
+					_ = c.Error(err).SetType(tt.errorType)
+				}
+			})
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": `))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expectedBody, extraDataOf(t, rep.errors[0])["body"])
+			}
+		})
+	}
+}
+
+func TestBindErrorBodyScrubbing(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "malformed body is redacted with the default fields",
+			body:         `{"user":"a","password":"hunter2",}`,
+			expectedBody: `{"user":"a","password":"********",}`,
+		},
+		{
+			name:         "valid body is scrubbed with the default fields",
+			body:         `{"user":1,"password":"hunter2"}`,
+			expectedBody: `{"password":"********","user":1}`,
+		},
+		{
+			name:         "configured fields replace the default ones",
+			opts:         []Option{WithScrubbedBodyFields("user")},
+			body:         `{"user":"a","password":"hunter2",}`,
+			expectedBody: `{"user":"********","password":"hunter2",}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.POST("/login", func(c *gin.Context) {
+				var payload struct {
+					User string `json:"user"`
+				}
+				if err := c.ShouldBindJSON(&payload); err != nil {
+					_ = c.Error(err).SetType(gin.ErrorTypeBind)
+				}
+			})
+
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expectedBody, extraDataOf(t, rep.errors[0])["body"])
+			}
+		})
+	}
+}
+
+func TestPanicTypeInExtraData(t *testing.T) {
+	t.Run("runtime errors get their type", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, ""))
+		router.GET("/", func(c *gin.Context) {
+			var m map[string]int
+			m["key"] = 1
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.criticals, 1) {
+			_, isRuntimeError := rep.criticals[0][0].(runtime.Error)
+			assert.True(t, isRuntimeError)
+			panicType, _ := extraDataOf(t, rep.criticals[0])["panic_type"].(string)
+			assert.True(t, strings.HasPrefix(panicType, "runtime."), panicType)
+		}
+	})
+
+	t.Run("other panics do not", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, ""))
+		router.GET("/", func(c *gin.Context) {
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.criticals, 1) {
+			assert.NotContains(t, extraDataOf(t, rep.criticals[0]), "panic_type")
+		}
+	})
+}
+
+func TestPanicInLaterMiddlewareDefer(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, ""))
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			panic("deferred panic")
+		}()
+		c.Next()
+	})
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := performRequest("GET", "/", router)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	if assert.Len(t, rep.criticals, 1) {
+		assert.EqualError(t, rep.criticals[0][0].(error), "deferred panic")
+	}
+}
+
+func TestResponseBytesInExtraData(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{name: "written body is counted", body: "partial response", expected: 16},
+		{name: "nothing written is zero", body: "", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, ""))
+			router.GET("/", func(c *gin.Context) {
+				if tt.body != "" {
+					c.String(http.StatusOK, tt.body)
+				}
+				_ = c.Error(errors.New("test error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expected, extraDataOf(t, rep.errors[0])["response_bytes"])
+			}
+		})
+	}
+}
+
+func TestErrorTypeInExtraData(t *testing.T) {
+	tests := []struct {
+		errorType gin.ErrorType
+		expected  string
+	}{
+		{errorType: gin.ErrorTypePublic, expected: "public"},
+		{errorType: gin.ErrorTypeBind, expected: "bind"},
+		{errorType: gin.ErrorTypeBind | gin.ErrorTypePrivate, expected: "bind|private"},
+		{errorType: gin.ErrorTypeAny, expected: "any"},
+		{errorType: 0, expected: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, ""))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error")).SetType(tt.errorType)
+			})
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expected, extraDataOf(t, rep.errors[0])["error_type"])
+			}
+		})
+	}
+}
+
+func TestPanickingRollbarCall(t *testing.T) {
+	captureReports(t)
+	RollbarCritical = func(...interface{}) {
+		panic("rollbar failure")
+	}
+	logger := &fakeLogger{}
+	var recovered interface{}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			recovered = recover()
+		}()
+		c.Next()
+	})
+	router.Use(LogRequests(false, false, "", WithLogger(logger)))
+	router.GET("/", func(c *gin.Context) {
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Equal(t, "occurs panic", recovered)
+	assert.Contains(t, logger.lines, "ginrollbar: reporting critical on / failed: rollbar failure")
+}
+
+func TestDuplicateMiddleware(t *testing.T) {
+	rep := captureReports(t)
+	logger := &fakeLogger{}
+	router := newTestRouter(LogRequests(false, false, "", WithLogger(logger)))
+	router.Use(LogRequests(false, false, "", WithLogger(logger)))
+	router.GET("/panic", panickingHandler)
+	router.GET("/error", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+
+	performRequest("GET", "/panic", router)
+	performRequest("GET", "/panic", router)
+	performRequest("GET", "/error", router)
+
+	assert.Len(t, rep.criticals, 2)
+	assert.Len(t, rep.errors, 1)
+	var warnings int
+	for _, line := range logger.lines {
+		if strings.Contains(line, "registered twice") {
+			warnings++
+		}
+	}
+	assert.Equal(t, 1, warnings)
+
+	t.Run("the second instance keeps its recovery", func(t *testing.T) {
+		rep := captureReports(t)
+		var hooked []interface{}
+		router := newTestRouter(LogRequests(false, false, "request_id"))
+		router.Use(LogRequests(false, false, "request_id",
+			WithRecover(true),
+			WithPanicResponse(http.StatusServiceUnavailable, gin.H{"error": "unavailable"}),
+			WithRequestIDGenerator(func() string { return "generated" }),
+			WithPanicHook(func(c *gin.Context, recovered interface{}) {
+				hooked = append(hooked, recovered)
+			}),
+		))
+		router.GET("/panic", panickingHandler)
+
+		w := performRequest("GET", "/panic", router)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"error":"unavailable"}`, w.Body.String())
+		assert.Equal(t, "generated", w.Header().Get("request_id"))
+		assert.Equal(t, []interface{}{"occurs panic"}, hooked)
+		assert.Len(t, rep.criticals, 1, "the panic stopped by the second instance is reported once")
+	})
+
+	t.Run("the first instance reports what the second re-panics", func(t *testing.T) {
+		rep := captureReports(t)
+		var hooked int
+		router := newTestRouter(LogRequests(false, false, "", WithRecover(true)))
+		router.Use(LogRequests(false, false, "", WithPanicHook(func(c *gin.Context, recovered interface{}) {
+			hooked++
+		})))
+		router.GET("/panic", panickingHandler)
+
+		w := performRequest("GET", "/panic", router)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, 1, hooked)
+		assert.Len(t, rep.criticals, 1)
+	})
+}
+
+// firstFrame returns the method of the innermost frame of the stack in a rollbar payload
+func firstFrame(t *testing.T, data map[string]interface{}) string {
+	t.Helper()
+	encoded, err := json.Marshal(data["body"])
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	type trace struct {
+		Frames []struct {
+			Method string `json:"method"`
+		} `json:"frames"`
+	}
+	var body struct {
+		Trace      *trace  `json:"trace"`
+		TraceChain []trace `json:"trace_chain"`
+	}
+	if !assert.NoError(t, json.Unmarshal(encoded, &body)) {
+		return ""
+	}
+	if body.Trace == nil && assert.NotEmpty(t, body.TraceChain) {
+		body.Trace = &body.TraceChain[0]
+	}
+	if body.Trace == nil || !assert.NotEmpty(t, body.Trace.Frames) {
+		return ""
+	}
+	return body.Trace.Frames[0].Method
+}
+
+// recoverAndReport reports the panic of its caller with ReportPanic
+func recoverAndReport(c *gin.Context) {
+	if r := recover(); r != nil {
+		ReportPanic(c, r)
+	}
+}
+
+func TestPanicStackFirstFrame(t *testing.T) {
+	payloads := make(chan map[string]interface{}, 1)
+	transform := func(data map[string]interface{}) {
+		payloads <- data
+	}
+	captureReports(t)
+	RollbarCritical = skippingMiddlewareFrames(rollbar.Critical)
+	RollbarWarning = skippingMiddlewareFrames(rollbar.Warning)
+	RollbarCriticalWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		rollbar.Critical(append(addStackSkip(interfaces, middlewareFrames), ctx)...)
+	}
+	rollbar.SetTransform(transform)
+	t.Cleanup(func() { rollbar.SetTransform(func(map[string]interface{}) {}) })
+	client := rollbar.NewSync("", "test", "", "", "")
+	client.SetTransform(transform)
+	warning := WithPanicLevelFunc(func(interface{}) string { return rollbar.WARN })
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		handler  gin.HandlerFunc
+		expected string
+	}{
+		{name: "package functions"},
+		{name: "package functions with context", opts: []Option{WithContextPropagation(true)}},
+		{name: "package warning function with context", opts: []Option{warning, WithContextPropagation(true)}},
+		{name: "client", opts: []Option{WithClient(client)}},
+		{name: "client with context", opts: []Option{WithClient(client), WithContextPropagation(true)}},
+		{
+			name: "ReportPanic",
+			handler: func(c *gin.Context) {
+				defer recoverAndReport(c)
+				panic("occurs panic")
+			},
+			expected: "v2.recoverAndReport",
+		},
+		{
+			name: "Go",
+			handler: func(c *gin.Context) {
+				Go(c, func() {
+					panic("occurs panic")
+				})
+			},
+			expected: "v2.Go.func1.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			handler, expected := tt.handler, tt.expected
+			if handler == nil {
+				handler, expected = panickingHandler, "v2.handle.func1"
+			}
+			router.GET("/", handler)
+
+			performRequest("GET", "/", router)
+
+			select {
+			case payload := <-payloads:
+				assert.Equal(t, expected, firstFrame(t, payload))
+			case <-time.After(time.Second):
+				t.Fatal("no report")
+			}
+		})
+	}
+}
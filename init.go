@@ -0,0 +1,53 @@
+package ginrollbar
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
+)
+
+// allow monkey-patching
+var (
+	RollbarSetToken       = rollbar.SetToken
+	RollbarSetEnvironment = rollbar.SetEnvironment
+	RollbarSetCodeVersion = rollbar.SetCodeVersion
+	RollbarSetServerHost  = rollbar.SetServerHost
+	RollbarSetServerRoot  = rollbar.SetServerRoot
+)
+
+// Config sets up the global rollbar client and the middleware returned by Init,
+// empty rollbar settings keep their current value
+type Config struct {
+	Token       string
+	Environment string
+	CodeVersion string
+	ServerHost  string
+	ServerRoot  string
+
+	OnlyPanics      bool
+	PrintStack      bool
+	RequestIDCtxKey string
+	Options         []Option
+}
+
+// New returns the middleware reporting errors and panics, configured by opts
+func New(opts ...Option) gin.HandlerFunc {
+	return LogRequests(false, false, "", opts...)
+}
+
+// Init configures the global rollbar client and returns the middleware in one call.
+// It can be called again, e.g. for another router, with the same result
+func Init(cfg Config) gin.HandlerFunc {
+	setIfNotEmpty(RollbarSetToken, cfg.Token)
+	setIfNotEmpty(RollbarSetEnvironment, cfg.Environment)
+	setIfNotEmpty(RollbarSetCodeVersion, cfg.CodeVersion)
+	setIfNotEmpty(RollbarSetServerHost, cfg.ServerHost)
+	setIfNotEmpty(RollbarSetServerRoot, cfg.ServerRoot)
+
+	return LogRequests(cfg.OnlyPanics, cfg.PrintStack, cfg.RequestIDCtxKey, cfg.Options...)
+}
+
+func setIfNotEmpty(set func(string), value string) {
+	if value != "" {
+		set(value)
+	}
+}
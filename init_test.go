@@ -0,0 +1,69 @@
+package ginrollbar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+	setToken, setEnvironment, setCodeVersion := RollbarSetToken, RollbarSetEnvironment, RollbarSetCodeVersion
+	setServerHost, setServerRoot := RollbarSetServerHost, RollbarSetServerRoot
+	t.Cleanup(func() {
+		RollbarSetToken, RollbarSetEnvironment, RollbarSetCodeVersion = setToken, setEnvironment, setCodeVersion
+		RollbarSetServerHost, RollbarSetServerRoot = setServerHost, setServerRoot
+	})
+	settings := map[string]string{}
+	record := func(name string) func(string) {
+		return func(value string) {
+			settings[name] = value
+		}
+	}
+	RollbarSetToken = record("token")
+	RollbarSetEnvironment = record("environment")
+	RollbarSetCodeVersion = record("code_version")
+	RollbarSetServerHost = record("server_host")
+	RollbarSetServerRoot = record("server_root")
+
+	rep := captureReports(t)
+	middleware := Init(Config{
+		Token:       "token",
+		Environment: "production",
+		CodeVersion: "v1.2.3",
+		Options:     []Option{WithTags(map[string]string{"service": "payments"})},
+	})
+	router := newTestRouter(middleware)
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Equal(t, map[string]string{
+		"token":        "token",
+		"environment":  "production",
+		"code_version": "v1.2.3",
+	}, settings)
+	assert.Len(t, rep.errors, 1)
+	if assert.Len(t, rep.criticals, 1) {
+		assert.Equal(t, map[string]string{"service": "payments"}, extraDataOf(t, rep.criticals[0])["tags"])
+	}
+}
+
+func TestNew(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(New(WithLastErrorOnly(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("first error"))
+		_ = c.Error(errors.New("second error"))
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.EqualError(t, rep.errors[0][0].(error), "second error")
+	}
+}
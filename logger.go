@@ -0,0 +1,78 @@
+package ginrollbar
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
+)
+
+// Logger receives a line for every report forwarded to rollbar, it is satisfied by
+// zap's SugaredLogger and logrus loggers
+type Logger interface {
+	Errorf(format string, args ...interface{})
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// StdLogger adapts a standard library logger to Logger, log.Default() is used when logger is nil
+func StdLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return stdLogger{logger: logger}
+}
+
+func (l stdLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Printf(format, args...)
+}
+
+// slogLevels maps the rollbar levels to the slog ones, unknown levels are logged as errors
+var slogLevels = map[string]slog.Level{
+	rollbar.DEBUG: slog.LevelDebug,
+	rollbar.INFO:  slog.LevelInfo,
+	rollbar.WARN:  slog.LevelWarn,
+	rollbar.ERR:   slog.LevelError,
+	rollbar.CRIT:  slog.LevelError,
+}
+
+// logSlog emits the structured record of a report when WithSlog is set
+func (cfg *config) logSlog(c *gin.Context, kind, level string, err error, fields map[string]interface{}) {
+	if cfg.slogger == nil {
+		return
+	}
+	slogLevel, ok := slogLevels[level]
+	if !ok {
+		slogLevel = slog.LevelError
+	}
+	attrs := make([]slog.Attr, 0, 5+len(fields))
+	attrs = append(attrs,
+		slog.String("kind", kind),
+		slog.String("level", level),
+		slog.String("endpoint", cfg.endpoint(c)),
+		slog.Int("status", c.Writer.Status()),
+		slog.String("error", err.Error()),
+	)
+	for _, key := range cfg.loggerFields {
+		if value, ok := fields[key]; ok {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+	cfg.slogger.LogAttrs(requestContext(c), slogLevel, "ginrollbar: "+kind+" reported", attrs...)
+}
+
+// formatFields formats the selected fields as " key=value" pairs in the order of keys
+func formatFields(keys []string, fields map[string]interface{}) string {
+	var b strings.Builder
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			fmt.Fprintf(&b, " %s=%v", key, value)
+		}
+	}
+	return b.String()
+}
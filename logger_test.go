@@ -0,0 +1,90 @@
+package ginrollbar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLogger records the formatted lines
+type fakeLogger struct {
+	lines []string
+}
+
+func (l *fakeLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := StdLogger(log.New(&buf, "", 0))
+
+	logger.Errorf("reporting %s", "error")
+
+	assert.Equal(t, "reporting error\n", buf.String())
+	assert.NotNil(t, StdLogger(nil))
+}
+
+// recordingHandler records the slog records with their attributes
+type recordingHandler struct {
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func attrsOf(record slog.Record) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value.Any()
+		return true
+	})
+	return attrs
+}
+
+func TestWithSlog(t *testing.T) {
+	captureReports(t)
+	handler := &recordingHandler{}
+	router := newTestRouter(LogRequests(false, false, "", WithSlog(slog.New(handler))))
+	router.GET("/error", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		c.Status(http.StatusBadRequest)
+	})
+	router.GET("/panic", panickingHandler)
+
+	performRequest("GET", "/error", router)
+	performRequest("GET", "/panic", router)
+
+	if assert.Len(t, handler.records, 2) {
+		assert.Equal(t, slog.LevelError, handler.records[0].Level)
+		assert.Equal(t, map[string]interface{}{
+			"kind":     "error",
+			"level":    "error",
+			"endpoint": "/error",
+			"status":   int64(http.StatusBadRequest),
+			"error":    "test error",
+		}, attrsOf(handler.records[0]))
+
+		attrs := attrsOf(handler.records[1])
+		assert.Equal(t, "panic", attrs["kind"])
+		assert.Equal(t, "critical", attrs["level"])
+		assert.Equal(t, "/panic", attrs["endpoint"])
+		assert.Equal(t, "occurs panic", attrs["error"])
+	}
+}
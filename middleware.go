@@ -0,0 +1,51 @@
+package ginrollbar
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware holds the settings of the middleware, they can be changed while it serves
+// requests, e.g. from an admin endpoint. Requests already running keep their settings
+type Middleware struct {
+	mu  sync.RWMutex
+	cfg *config
+}
+
+// NewMiddleware returns the middleware of LogRequests, with the same arguments, before it
+// is turned into a gin handler
+func NewMiddleware(onlyPanics, printStack bool, requestIdCtxKey string, opts ...Option) *Middleware {
+	return &Middleware{cfg: newConfig(onlyPanics, printStack, requestIdCtxKey, opts...)}
+}
+
+// Handler returns the gin handler reporting with the current settings
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handle(c, m.config().forRoute(c.FullPath()), (*gin.Context).Next)
+	}
+}
+
+// SampleRate returns the current sample rate of errors, see WithSampleRate
+func (m *Middleware) SampleRate() float64 {
+	return m.config().sampleRate
+}
+
+// SetSampleRate changes the sample rate of errors, see WithSampleRate
+func (m *Middleware) SetSampleRate(rate float64) {
+	m.update(WithSampleRate(rate))
+}
+
+func (m *Middleware) config() *config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+// update applies opt to a copy of the settings, including those of the route overrides,
+// so requests holding the current ones are not affected
+func (m *Middleware) update(opt Option) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = m.cfg.with(opt)
+}
@@ -0,0 +1,62 @@
+package ginrollbar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareSetSampleRate(t *testing.T) {
+	rep := captureReports(t)
+	middleware := NewMiddleware(false, false, "", WithRouteOverrides(map[string]Option{
+		"/override": WithCaptureQueryParams(true),
+	}))
+	router := newTestRouter(middleware.Handler())
+	handler := func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	}
+	router.GET("/", handler)
+	router.GET("/override", handler)
+
+	performRequest("GET", "/", router)
+	performRequest("GET", "/override", router)
+	assert.Len(t, rep.errors, 2)
+
+	middleware.SetSampleRate(0)
+	assert.Equal(t, float64(0), middleware.SampleRate())
+	performRequest("GET", "/", router)
+	performRequest("GET", "/override", router)
+	assert.Len(t, rep.errors, 2)
+
+	middleware.SetSampleRate(1)
+	performRequest("GET", "/override", router)
+	if assert.Len(t, rep.errors, 3) {
+		assert.Contains(t, extraDataOf(t, rep.errors[2]), "query")
+	}
+}
+
+func TestMiddlewareSetterKeepsRouteOverrides(t *testing.T) {
+	rep := captureReports(t)
+	middleware := NewMiddleware(false, false, "", WithRouteOverrides(map[string]Option{
+		"/noisy": WithSampleRate(0),
+	}))
+	router := newTestRouter(middleware.Handler())
+	handler := func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	}
+	router.GET("/", handler)
+	router.GET("/noisy", handler)
+
+	performRequest("GET", "/noisy", router)
+	middleware.SetSampleRate(1)
+	performRequest("GET", "/noisy", router)
+	assert.Empty(t, rep.errors, "the route override wins over the setter")
+
+	middleware.SetSampleRate(0)
+	performRequest("GET", "/", router)
+	middleware.SetSampleRate(1)
+	performRequest("GET", "/", router)
+	assert.Len(t, rep.errors, 1)
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package ginrollbar
+
+const raceEnabled = false
@@ -0,0 +1,872 @@
+package ginrollbar
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
+)
+
+// defaultScrubbedHeaders are masked by WithScrubbedHeaders when no names are given
+var defaultScrubbedHeaders = []string{"Authorization", "Cookie", "X-Api-Key"}
+
+// Option customizes the behaviour of the middleware returned by LogRequests
+type Option func(*config)
+
+type config struct {
+	onlyPanics      bool
+	printStack      bool
+	requestIdCtxKey string
+
+	requestIDFromContext    bool
+	requestIDGenerator      func() string
+	errorLevelFunc          func(*gin.Error) string
+	ignoredStatusCodes      map[int]struct{}
+	ignoredPaths            map[string]struct{}
+	captureQueryParams      bool
+	scrubbedHeaders         []string
+	personFunc              func(*gin.Context) (id, username, email string)
+	recover                 bool
+	panicStatus             int
+	panicBody               interface{}
+	panicStatusFunc         func(interface{}) int
+	captureStack            bool
+	sampleRate              float64
+	deduper                 *deduper
+	synchronous             bool
+	extraDataFunc           func(*gin.Context) map[string]interface{}
+	privateErrorsAsInfo     bool
+	clientIP                bool
+	captureBodyBytes        int
+	scrubbedBodyFields      []string
+	preserveStack           bool
+	additionalReporter      func(err error, req *http.Request, extra map[string]interface{})
+	enabled                 bool
+	errorFilter             func(*gin.Error) bool
+	reportErrorTypes        gin.ErrorType
+	lastErrorOnly           bool
+	fingerprintFunc         func(*gin.Context, error) string
+	contextPropagation      bool
+	logger                  Logger
+	slogger                 *slog.Logger
+	stackSkip               int
+	capturedHeaders         []string
+	minStatusCode           int
+	titleFunc               func(*gin.Context, error) string
+	unwrapErrors            bool
+	capturedResponseHeaders []string
+	environment             string
+	codeVersion             string
+	delegateRecovery        bool
+	combinePanicContext     bool
+	allowOverrideReserved   bool
+	panicLevelFunc          func(interface{}) string
+	structuredMeta          bool
+	metaSerializer          func(interface{}) interface{}
+	keyNames                map[string]string
+	client                  *rollbar.Client
+	tags                    map[string]string
+	reportCounter           func(string)
+	timeoutLevel            string
+	withoutRequest          bool
+	batchErrors             bool
+	statusLevelFunc         func(int) string
+	runtimeStats            bool
+	panicHook               func(*gin.Context, interface{})
+	stderrFallback          bool
+	contextKeys             []string
+	queue                   *asyncQueue
+	remoteAddr              bool
+	shutdownGuard           func() bool
+	protocolInfo            bool
+	clientDisconnectLevel   string
+	traceContext            bool
+	refererOrigin           bool
+	skipSuccessfulResponses bool
+	maxExtraDataBytes       int
+	before                  func(*gin.Context, string, error) (bool, map[string]interface{})
+	routeOverrides          map[string]Option
+	routeConfigs            map[string]*config
+	duplicateWarning        *sync.Once
+	stripQuery              bool
+	rollbarFields           []string
+	loggerFields            []string
+	goRepanic               bool
+	panicErrorFunc          func(interface{}) error
+	dryRun                  *dryRunStore
+	userAgentParsing        bool
+	suppressInTestMode      bool
+	serverHost              bool
+	cookieNames             bool
+	afterReport             func(*gin.Context, string, error)
+	reportPanics            bool
+	wrappedHandler          string
+}
+
+func newConfig(onlyPanics, printStack bool, requestIdCtxKey string, opts ...Option) *config {
+	cfg := &config{
+		onlyPanics:            onlyPanics,
+		printStack:            printStack,
+		requestIdCtxKey:       requestIdCtxKey,
+		panicStatus:           http.StatusInternalServerError,
+		sampleRate:            1,
+		enabled:               true,
+		stackSkip:             3,
+		clientDisconnectLevel: rollbar.INFO,
+		reportPanics:          true,
+		duplicateWarning:      &sync.Once{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.buildRouteConfigs()
+	return cfg
+}
+
+// buildRouteConfigs derives the settings of each route from cfg and its route override,
+// so the overrides win over the settings of the middleware
+func (cfg *config) buildRouteConfigs() {
+	cfg.routeConfigs = nil
+	if len(cfg.routeOverrides) == 0 {
+		return
+	}
+	cfg.routeConfigs = make(map[string]*config, len(cfg.routeOverrides))
+	for route, opt := range cfg.routeOverrides {
+		routeCfg := cfg.clone()
+		routeCfg.routeOverrides, routeCfg.routeConfigs = nil, nil
+		opt(routeCfg)
+		cfg.routeConfigs[route] = routeCfg
+	}
+}
+
+// clone returns a copy of cfg that options can change without affecting cfg,
+// the deduper and the async queue are shared
+func (cfg *config) clone() *config {
+	clone := *cfg
+	clone.ignoredStatusCodes = maps.Clone(cfg.ignoredStatusCodes)
+	clone.ignoredPaths = maps.Clone(cfg.ignoredPaths)
+	clone.scrubbedHeaders = slices.Clip(cfg.scrubbedHeaders)
+	clone.scrubbedBodyFields = slices.Clip(cfg.scrubbedBodyFields)
+	clone.capturedHeaders = slices.Clip(cfg.capturedHeaders)
+	clone.capturedResponseHeaders = slices.Clip(cfg.capturedResponseHeaders)
+	clone.contextKeys = slices.Clip(cfg.contextKeys)
+	clone.rollbarFields = slices.Clip(cfg.rollbarFields)
+	clone.loggerFields = slices.Clip(cfg.loggerFields)
+	return &clone
+}
+
+// with returns a copy of cfg with opt applied, the route settings are derived again so
+// their overrides still win
+func (cfg *config) with(opt Option) *config {
+	updated := cfg.clone()
+	opt(updated)
+	updated.buildRouteConfigs()
+	return updated
+}
+
+// forRoute returns the settings of the given route template
+func (cfg *config) forRoute(route string) *config {
+	if routeCfg, ok := cfg.routeConfigs[route]; ok {
+		return routeCfg
+	}
+	return cfg
+}
+
+// WithRequestIDFromContext looks up the request id in the gin context keys (c.Get)
+// before falling back to the response header of the same name
+func WithRequestIDFromContext(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.requestIDFromContext = enabled
+	}
+}
+
+// WithRequestIDGenerator generates the request id, e.g. a UUID, when none is found under
+// requestIdCtxKey as the middleware starts. It is set in the gin context keys and the
+// response header of that name so every report carries a correlation id
+func WithRequestIDGenerator(fn func() string) Option {
+	return func(cfg *config) {
+		cfg.requestIDGenerator = fn
+	}
+}
+
+// WithErrorLevelFunc maps each gin error to a rollbar level ("error", "warning", "info" or "debug").
+// An empty or unknown level is reported as an error
+func WithErrorLevelFunc(fn func(*gin.Error) string) Option {
+	return func(cfg *config) {
+		cfg.errorLevelFunc = fn
+	}
+}
+
+// WithIgnoredStatusCodes skips reporting errors and panics when the response status
+// matches one of the given codes. Panics are still re-panicked
+func WithIgnoredStatusCodes(codes ...int) Option {
+	return func(cfg *config) {
+		if cfg.ignoredStatusCodes == nil {
+			cfg.ignoredStatusCodes = make(map[int]struct{}, len(codes))
+		}
+		for _, code := range codes {
+			cfg.ignoredStatusCodes[code] = struct{}{}
+		}
+	}
+}
+
+// WithIgnoredPaths skips reporting for the given route templates as returned by
+// c.FullPath(), e.g. "/users/:id" rather than "/users/42"
+func WithIgnoredPaths(paths ...string) Option {
+	return func(cfg *config) {
+		if cfg.ignoredPaths == nil {
+			cfg.ignoredPaths = make(map[string]struct{}, len(paths))
+		}
+		for _, path := range paths {
+			cfg.ignoredPaths[path] = struct{}{}
+		}
+	}
+}
+
+// WithCaptureQueryParams adds the parsed query parameters to the extra data under "query".
+// Repeated values are joined with commas and sensitive keys (password, secret, token) are masked
+func WithCaptureQueryParams(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.captureQueryParams = enabled
+	}
+}
+
+// WithScrubbedHeaders masks the given request headers with "********" before the request
+// is sent to rollbar. Without names, Authorization, Cookie and X-Api-Key are masked
+func WithScrubbedHeaders(names ...string) Option {
+	return func(cfg *config) {
+		if len(names) == 0 {
+			names = defaultScrubbedHeaders
+		}
+		for _, name := range names {
+			cfg.scrubbedHeaders = append(cfg.scrubbedHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithPersonFromContext adds the user returned by fn to the extra data under "person".
+// Nothing is attached when the returned id is empty
+func WithPersonFromContext(fn func(*gin.Context) (id, username, email string)) Option {
+	return func(cfg *config) {
+		cfg.personFunc = fn
+	}
+}
+
+// WithRecover makes the middleware the terminal recovery handler: after reporting a panic
+// it aborts with a 500 instead of re-panicking
+func WithRecover(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.recover = enabled
+	}
+}
+
+// WithPanicResponse sets the response written for swallowed panics in recover mode,
+// the body is rendered as JSON unless it is nil
+func WithPanicResponse(status int, body interface{}) Option {
+	return func(cfg *config) {
+		cfg.panicStatus = status
+		cfg.panicBody = body
+	}
+}
+
+// WithCaptureStack adds the stack trace of the panicking goroutine to the panic
+// extra data under "stack", independently of printStack
+func WithCaptureStack(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.captureStack = enabled
+	}
+}
+
+// WithSampleRate reports only a random fraction of errors, between 0.0 (none) and 1.0 (all).
+// It applies to errors only, panics are always reported
+func WithSampleRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.sampleRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithDedupeWindow suppresses errors identical to one sent for the same endpoint less than
+// window ago. The next occurrence sent after the window carries the suppressed count under "occurrences"
+func WithDedupeWindow(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.deduper = newDeduper(window)
+	}
+}
+
+// WithSynchronous waits for rollbar to send the reports before the middleware returns.
+// Delivery is guaranteed at the cost of request latency, which suits tests and CLI tools
+func WithSynchronous(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.synchronous = enabled
+	}
+}
+
+// WithExtraData merges the map returned by fn into the extra data of every report.
+// Keys already set by the middleware, such as "endpoint", are not overwritten unless
+// WithAllowOverrideReserved is set. Fields specific to the reported error or panic, such
+// as "meta" or "stack", are added afterwards and always win
+func WithExtraData(fn func(*gin.Context) map[string]interface{}) Option {
+	return func(cfg *config) {
+		cfg.extraDataFunc = fn
+	}
+}
+
+// WithPrivateErrorsAsInfo reports errors of type gin.ErrorTypePrivate at info level through
+// RollbarInfo, as diagnostic context rather than failures. Note that c.Error and c.AbortWithError
+// mark plain errors as private. WithErrorLevelFunc takes precedence when both are set
+func WithPrivateErrorsAsInfo(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.privateErrorsAsInfo = enabled
+	}
+}
+
+// WithClientIP adds the client IP resolved by c.ClientIP() to the extra data under "ip",
+// honouring the trusted proxies configured on the gin engine
+func WithClientIP(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.clientIP = enabled
+	}
+}
+
+// WithCaptureBody adds up to maxBytes of the request body to the extra data under "body".
+// The body is buffered before the handlers run and restored for them, but only attached
+// to the extra data when an error or panic is reported
+func WithCaptureBody(maxBytes int) Option {
+	return func(cfg *config) {
+		cfg.captureBodyBytes = maxBytes
+	}
+}
+
+// WithScrubbedBodyFields masks the values of the given keys, at any depth and case-insensitively,
+// in captured JSON bodies. Bodies that are not valid JSON, e.g. truncated ones, are redacted
+// textually. Without fields, the body of bind errors is scrubbed of password, secret and token keys
+func WithScrubbedBodyFields(fields ...string) Option {
+	return func(cfg *config) {
+		cfg.scrubbedBodyFields = append(cfg.scrubbedBodyFields, fields...)
+	}
+}
+
+// WithPreserveStack stores the stack of the panicking goroutine, captured before the re-panic
+// replaces it, in the gin context under StackKey so outer recovery handlers can log it
+func WithPreserveStack(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.preserveStack = enabled
+	}
+}
+
+// WithAdditionalReporter sends every report to fn as well, e.g. a second rollbar client.
+// fn runs even if the primary rollbar call panics and a panic in fn is ignored
+func WithAdditionalReporter(fn func(err error, req *http.Request, extra map[string]interface{})) Option {
+	return func(cfg *config) {
+		cfg.additionalReporter = fn
+	}
+}
+
+// WithEnabled turns reporting on or off (on by default). When off nothing is sent to rollbar,
+// but panics are still re-panicked or recovered so the request flow is unchanged
+func WithEnabled(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.enabled = enabled
+	}
+}
+
+// WithErrorFilter is evaluated for each gin error, returning false skips reporting that error
+func WithErrorFilter(fn func(*gin.Error) bool) Option {
+	return func(cfg *config) {
+		cfg.errorFilter = fn
+	}
+}
+
+// WithReportErrorTypes only reports the gin errors whose type matches the mask, e.g.
+// gin.ErrorTypePublic|gin.ErrorTypeBind to ignore private errors. 0 reports every type
+func WithReportErrorTypes(types gin.ErrorType) Option {
+	return func(cfg *config) {
+		cfg.reportErrorTypes = types
+	}
+}
+
+// WithLastErrorOnly reports only the last gin error of a request instead of all of them
+func WithLastErrorOnly(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.lastErrorOnly = enabled
+	}
+}
+
+// WithFingerprintFunc adds the fingerprint returned by fn to the extra data under "fingerprint"
+// to control how rollbar groups occurrences. An empty fingerprint keeps the default grouping.
+// Install Transform with rollbar.SetTransform so rollbar uses it
+func WithFingerprintFunc(fn func(*gin.Context, error) string) Option {
+	return func(cfg *config) {
+		cfg.fingerprintFunc = fn
+	}
+}
+
+// WithContextPropagation passes the request context to rollbar through RollbarErrorWithContext
+// and RollbarCriticalWithContext, so request-scoped values such as the person are available to it
+func WithContextPropagation(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.contextPropagation = enabled
+	}
+}
+
+// WithLogger logs a line with the level, endpoint and error of every report forwarded
+// to rollbar. Use StdLogger to adapt a standard library logger
+func WithLogger(logger Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = logger
+	}
+}
+
+// WithSlog emits a structured record with the endpoint, status, error and kind of every
+// report, "error" or "panic", at the slog level matching the rollbar level
+func WithSlog(logger *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.slogger = logger
+	}
+}
+
+// WithStackSkip sets the number of stack frames rollbar skips when capturing the stack
+// of a panic, 3 by default which starts the stack at the function recovering the panic.
+// RollbarCritical and the other monkey-patchable functions are given skip as is, their
+// defaults skip the frames of the middleware and of rollbar-go on top of it
+func WithStackSkip(skip int) Option {
+	return func(cfg *config) {
+		cfg.stackSkip = skip
+	}
+}
+
+// WithCapturedHeaders copies the given request headers, matched case-insensitively, to the
+// extra data under "headers". Missing headers are omitted and scrubbed headers are masked
+func WithCapturedHeaders(names ...string) Option {
+	return func(cfg *config) {
+		for _, name := range names {
+			cfg.capturedHeaders = append(cfg.capturedHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithMinStatusCode skips reporting errors when the response status is below code,
+// e.g. 500 to ignore client errors. Panics are always reported
+func WithMinStatusCode(code int) Option {
+	return func(cfg *config) {
+		cfg.minStatusCode = code
+	}
+}
+
+// WithTitleFunc adds the title returned by fn to the extra data under "title" to override the
+// occurrence title rollbar derives from the error message, an empty title keeps the default.
+// Install Transform with rollbar.SetTransform so rollbar uses it
+func WithTitleFunc(fn func(*gin.Context, error) string) Option {
+	return func(cfg *config) {
+		cfg.titleFunc = fn
+	}
+}
+
+// WithUnwrapErrors adds the messages of the reported error and of every error it wraps,
+// outermost first, to the extra data under "cause_chain"
+func WithUnwrapErrors(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.unwrapErrors = enabled
+	}
+}
+
+// WithCapturedResponseHeaders copies the given response headers, as set by the handlers,
+// to the extra data under "response_headers". Missing headers are omitted
+func WithCapturedResponseHeaders(names ...string) Option {
+	return func(cfg *config) {
+		for _, name := range names {
+			cfg.capturedResponseHeaders = append(cfg.capturedResponseHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithEnvironment reports the occurrences of this middleware under environment instead of the
+// global rollbar one. Install Transform with rollbar.SetTransform so rollbar uses it
+func WithEnvironment(environment string) Option {
+	return func(cfg *config) {
+		cfg.environment = environment
+	}
+}
+
+// WithCodeVersion reports the occurrences of this middleware with codeVersion instead of the
+// global rollbar one. Install Transform with rollbar.SetTransform so rollbar uses it
+func WithCodeVersion(codeVersion string) Option {
+	return func(cfg *config) {
+		cfg.codeVersion = codeVersion
+	}
+}
+
+// WithDelegateRecovery overrides WithRecover: the panic is handed over to an outer
+// gin.Recovery or gin.CustomRecovery after reporting it instead of being answered here.
+// Without WithRecover the middleware re-panics anyway and this option changes nothing.
+// The outer handler receives the original panic value and writes the response, the stack
+// it prints starts at the re-panic in this middleware
+func WithDelegateRecovery(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.delegateRecovery = enabled
+	}
+}
+
+// WithCombinePanicContext adds the messages of the gin errors to the panic report under
+// "prior_errors" instead of reporting them separately when the request panics
+func WithCombinePanicContext(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.combinePanicContext = enabled
+	}
+}
+
+// WithAllowOverrideReserved lets the data of WithExtraData overwrite the keys set by the
+// middleware for the request, such as "endpoint", "status_code" or "request_id"
+func WithAllowOverrideReserved(allowed bool) Option {
+	return func(cfg *config) {
+		cfg.allowOverrideReserved = allowed
+	}
+}
+
+// WithPanicLevelFunc sets the rollbar level of a panic from its recovered value, such as
+// rollbar.ERR for benign panics. Panics are reported as critical when fn returns ""
+func WithPanicLevelFunc(fn func(recovered interface{}) string) Option {
+	return func(cfg *config) {
+		cfg.panicLevelFunc = fn
+	}
+}
+
+// WithStructuredMeta attaches the meta of gin errors as is so rollbar serializes its
+// structure, instead of the default fmt.Sprint string
+func WithStructuredMeta(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.structuredMeta = enabled
+	}
+}
+
+// WithKeyNames renames the built-in extra data keys, such as "endpoint" to "url".
+// Unknown keys and the fields lifted by Transform are ignored
+func WithKeyNames(names map[string]string) Option {
+	return func(cfg *config) {
+		cfg.keyNames = make(map[string]string, len(names))
+		for key, name := range names {
+			if _, ok := renamableKeys[key]; ok && name != "" {
+				cfg.keyNames[key] = name
+			}
+		}
+	}
+}
+
+// WithClient reports through client instead of the global rollbar functions, e.g. to use
+// another token or environment. Flush only waits for the global client, call client.Wait
+func WithClient(client *rollbar.Client) Option {
+	return func(cfg *config) {
+		cfg.client = client
+	}
+}
+
+// WithTags adds static labels, such as "service": "payments", to every report under "tags".
+// The map is copied once when the middleware is created
+func WithTags(tags map[string]string) Option {
+	return func(cfg *config) {
+		cfg.tags = maps.Clone(tags)
+	}
+}
+
+// WithReportCounter calls fn with "error" or "panic" once for every report forwarded to
+// rollbar or queued by WithAsyncQueue, e.g. to increment a Prometheus counter. Reports that
+// are dry-run, suppressed, written to the fallback, dropped or whose rollbar call panics are
+// not counted. fn runs on the request goroutine and must not block
+func WithReportCounter(fn func(kind string)) Option {
+	return func(cfg *config) {
+		cfg.reportCounter = fn
+	}
+}
+
+// WithTimeoutLevel reports errors and panics of requests whose context was cancelled or
+// timed out at level, such as rollbar.WARN. These reports always carry "timeout": true
+func WithTimeoutLevel(level string) Option {
+	return func(cfg *config) {
+		cfg.timeoutLevel = level
+	}
+}
+
+// WithoutRequest never passes the *http.Request to rollbar or to the additional reporter,
+// only the curated extra data describes the request
+func WithoutRequest(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.withoutRequest = enabled
+	}
+}
+
+// WithBatchErrors sends the errors of a request as a single report of the first one,
+// the messages of the others are listed under "additional_errors"
+func WithBatchErrors(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.batchErrors = enabled
+	}
+}
+
+// WithStatusLevelFunc sets the rollbar level of gin errors from the response status, such as
+// rollbar.WARN for 4xx. WithErrorLevelFunc takes precedence, "" keeps the default level
+func WithStatusLevelFunc(fn func(status int) string) Option {
+	return func(cfg *config) {
+		cfg.statusLevelFunc = fn
+	}
+}
+
+// WithRuntimeStats adds the goroutine count and the allocated and system memory to panic
+// reports under "runtime". Errors never get them, reading the memory stats stops the world
+func WithRuntimeStats(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.runtimeStats = enabled
+	}
+}
+
+// WithPanicHook calls fn with the original recovered value after the panic is reported and
+// before it is re-panicked or answered. A panic in fn is logged and otherwise ignored
+func WithPanicHook(fn func(c *gin.Context, recovered interface{})) Option {
+	return func(cfg *config) {
+		cfg.panicHook = fn
+	}
+}
+
+// WithStderrFallback writes the reports to FallbackWriter, stderr by default, instead of
+// dropping them when rollbar has no token, e.g. in a misconfigured environment
+func WithStderrFallback(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.stderrFallback = enabled
+	}
+}
+
+// WithContextKeys copies the values set with c.Set under the given keys to the extra data
+// under "context", values that cannot be serialized to JSON are stringified
+func WithContextKeys(keys ...string) Option {
+	return func(cfg *config) {
+		cfg.contextKeys = append(cfg.contextKeys, keys...)
+	}
+}
+
+// WithAsyncQueue sends the reports from a background goroutine through a queue of size
+// reports, those that do not fit are dropped and counted by DroppedReports. Call Shutdown
+// before exiting to send the queued ones. Rollbar captures the stack of the goroutine
+// sending the report, so errors without their own stack trace lose the handler frames
+func WithAsyncQueue(size int) Option {
+	return func(cfg *config) {
+		if size > 0 {
+			cfg.queue = newAsyncQueue(size)
+		}
+	}
+}
+
+// WithRemoteAddr adds both the client IP resolved through the trusted proxies under
+// "client_ip" and the raw address of the immediate peer under "remote_addr"
+func WithRemoteAddr(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.remoteAddr = enabled
+	}
+}
+
+// WithShutdownGuard suppresses the error reports while fn returns true, e.g. during a graceful
+// shutdown when in-flight requests fail for reasons that are not bugs. Panics are still reported
+func WithShutdownGuard(fn func() bool) Option {
+	return func(cfg *config) {
+		cfg.shutdownGuard = fn
+	}
+}
+
+// WithProtocolInfo adds the protocol of the request, such as "HTTP/2.0", under "proto" and
+// whether it was received over TLS under "tls"
+func WithProtocolInfo(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.protocolInfo = enabled
+	}
+}
+
+// WithRouteOverrides applies an additional option on top of the others for the given route
+// templates as returned by c.FullPath(), e.g. to capture the body on "/api/users/:id" only
+func WithRouteOverrides(overrides map[string]Option) Option {
+	return func(cfg *config) {
+		cfg.routeOverrides = overrides
+	}
+}
+
+// WithClientDisconnectLevel sets the level of errors caused by the client closing the
+// connection, such as broken pipes, which carry "client_disconnect": true. Defaults to info
+func WithClientDisconnectLevel(level string) Option {
+	return func(cfg *config) {
+		cfg.clientDisconnectLevel = level
+	}
+}
+
+// WithTraceContext adds the OpenTelemetry trace and span ids of the request context under
+// "trace_id" and "span_id", they are omitted when the request has no span
+func WithTraceContext(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.traceContext = enabled
+	}
+}
+
+// WithMetaSerializer sets how the meta of gin errors is represented under "meta", e.g. to
+// pick some fields of a struct. It takes precedence over WithStructuredMeta
+func WithMetaSerializer(fn func(meta interface{}) interface{}) Option {
+	return func(cfg *config) {
+		cfg.metaSerializer = fn
+	}
+}
+
+// WithPanicStatusFunc sets the status of the response to a swallowed panic in recover mode
+// from its recovered value, 0 keeps the status of WithPanicResponse. The level is set
+// separately with WithPanicLevelFunc
+func WithPanicStatusFunc(fn func(recovered interface{}) int) Option {
+	return func(cfg *config) {
+		cfg.panicStatusFunc = fn
+	}
+}
+
+// WithRefererOrigin adds the Referer and Origin request headers under "referer" and "origin"
+// when they are set, e.g. to diagnose CSRF or CORS failures
+func WithRefererOrigin(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.refererOrigin = enabled
+	}
+}
+
+// WithSkipSuccessfulResponses suppresses the error reports of requests answered with a 2xx
+// status, e.g. when handlers attach diagnostic errors. Panics are still reported
+func WithSkipSuccessfulResponses(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.skipSuccessfulResponses = enabled
+	}
+}
+
+// WithMaxExtraDataBytes keeps the JSON encoding of the extra data under maxBytes so rollbar
+// does not reject the payload: the body, then the headers, then the largest fields are
+// dropped until it fits and "truncated": true is added
+func WithMaxExtraDataBytes(maxBytes int) Option {
+	return func(cfg *config) {
+		cfg.maxExtraDataBytes = maxBytes
+	}
+}
+
+// WithBefore calls fn just before each report with kind "error" or "panic". Returning false
+// cancels the report, the returned map is merged into the extra data like WithExtraData
+func WithBefore(fn func(c *gin.Context, kind string, err error) (report bool, extra map[string]interface{})) Option {
+	return func(cfg *config) {
+		cfg.before = fn
+	}
+}
+
+// WithStripQuery sets "endpoint" to the path without the query string so rollbar groups the
+// reports of an endpoint together, WithCaptureQueryParams still adds the query under "query"
+func WithStripQuery(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.stripQuery = enabled
+	}
+}
+
+// WithRollbarFields only sends the given extra data keys to rollbar, after WithKeyNames, e.g.
+// to keep sensitive fields local. The fingerprint, title, environment and code version are
+// always sent, the additional reporter still receives every field
+func WithRollbarFields(keys ...string) Option {
+	return func(cfg *config) {
+		cfg.rollbarFields = slices.Clone(keys)
+	}
+}
+
+// WithLoggerFields appends the given extra data keys, after WithKeyNames, to the lines of
+// WithLogger as key=value pairs and to the records of WithSlog as attributes
+func WithLoggerFields(keys ...string) Option {
+	return func(cfg *config) {
+		cfg.loggerFields = slices.Clone(keys)
+	}
+}
+
+// WithGoRepanic re-panics the panics of the goroutines started with Go once reported, which
+// crashes the process, instead of swallowing them
+func WithGoRepanic(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.goRepanic = enabled
+	}
+}
+
+// WithPanicErrorFunc builds the error reported for a recovered panic value, e.g. to add a
+// prefix or a correlation id. A nil error keeps the default: the value itself when it is an
+// error, its fmt.Sprint otherwise
+func WithPanicErrorFunc(fn func(recovered interface{}) error) Option {
+	return func(cfg *config) {
+		cfg.panicErrorFunc = fn
+	}
+}
+
+// WithDryRun records the reports in memory instead of sending them to rollbar, e.g. to
+// validate an integration in staging. Read them with Middleware.DryRunReports
+func WithDryRun(enabled bool) Option {
+	return func(cfg *config) {
+		if !enabled {
+			cfg.dryRun = nil
+		} else if cfg.dryRun == nil {
+			cfg.dryRun = &dryRunStore{}
+		}
+	}
+}
+
+// WithUserAgentParsing adds the User-Agent header under "user_agent" with the browser, os
+// and device class parsed from it under "browser", "os" and "device", "unknown" when the
+// parser does not recognize them
+func WithUserAgentParsing(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.userAgentParsing = enabled
+	}
+}
+
+// WithSuppressInTestMode skips the rollbar call while gin runs in test mode. The loggers and
+// the additional reporter still run, the report counter and the after report hook do not
+// since nothing is handed to rollbar. It is off by default so tests replacing the Rollbar*
+// functions keep receiving the reports
+func WithSuppressInTestMode(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.suppressInTestMode = enabled
+	}
+}
+
+// WithServerHost adds the hostname of the machine under "server_host", "unknown" when it
+// cannot be read, to tell apart the instances of a deployment
+func WithServerHost(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.serverHost = enabled
+	}
+}
+
+// WithCookieNames adds the names of the request cookies under "cookie_names", never their
+// values, e.g. to debug session issues
+func WithCookieNames(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.cookieNames = enabled
+	}
+}
+
+// WithAfterReport calls fn with kind "error" or "panic" once the report is handed to rollbar,
+// or to the async queue, e.g. to feed a circuit breaker. It runs before a panic is re-panicked,
+// not when the rollbar call panics or the async queue drops the report. A panic in fn is
+// logged and otherwise ignored
+func WithAfterReport(fn func(c *gin.Context, kind string, err error)) Option {
+	return func(cfg *config) {
+		cfg.afterReport = fn
+	}
+}
+
+// WithReportErrors toggles the reports of gin errors, it overrides the onlyPanics argument
+func WithReportErrors(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.onlyPanics = !enabled
+	}
+}
+
+// WithReportPanics toggles the reports of panics, e.g. when gin.Recovery already reports
+// them elsewhere. Panics are still recovered or re-panicked as configured
+func WithReportPanics(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.reportPanics = enabled
+	}
+}
@@ -0,0 +1,2749 @@
+package ginrollbar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rollbar/rollbar-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithRequestIDFromContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected string
+	}{
+		{name: "context value is preferred when enabled", enabled: true, expected: "abc"},
+		{name: "response header is used when disabled", enabled: false, expected: "from-header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "request_id", WithRequestIDFromContext(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				c.Set("request_id", "abc")
+				c.Header("request_id", "from-header")
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, 1)
+			assert.Len(t, rep.criticals, 1)
+			assert.Equal(t, tt.expected, extraDataOf(t, rep.errors[0])["request_id"])
+			assert.Equal(t, tt.expected, extraDataOf(t, rep.criticals[0])["request_id"])
+		})
+	}
+
+	t.Run("falls back to the response header when the context value is empty", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "request_id", WithRequestIDFromContext(true)))
+		router.GET("/", func(c *gin.Context) {
+			c.Set("request_id", "")
+			c.Header("request_id", "from-header")
+			c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.errors, 1)
+		assert.Equal(t, "from-header", extraDataOf(t, rep.errors[0])["request_id"])
+	})
+}
+
+func TestWithRequestIDGenerator(t *testing.T) {
+	generator := func() string { return "generated" }
+
+	t.Run("generated id is reported and set on the response", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "X-Request-Id", WithRequestIDGenerator(generator)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, "generated", w.Header().Get("X-Request-Id"))
+		if assert.Len(t, rep.errors, 1) {
+			assert.Equal(t, "generated", extraDataOf(t, rep.errors[0])["request_id"])
+		}
+	})
+
+	t.Run("upstream id is kept", func(t *testing.T) {
+		rep := captureReports(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Header("X-Request-Id", "upstream")
+		})
+		router.Use(LogRequests(false, false, "X-Request-Id", WithRequestIDGenerator(generator)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, "upstream", w.Header().Get("X-Request-Id"))
+		if assert.Len(t, rep.errors, 1) {
+			assert.Equal(t, "upstream", extraDataOf(t, rep.errors[0])["request_id"])
+		}
+	})
+}
+
+func TestWithErrorLevelFunc(t *testing.T) {
+	levelByType := func(item *gin.Error) string {
+		switch item.Type {
+		case gin.ErrorTypeBind:
+			return "warning"
+		case gin.ErrorTypePrivate:
+			return "info"
+		case gin.ErrorTypeRender:
+			return "debug"
+		default:
+			return "error"
+		}
+	}
+
+	t.Run("errors are dispatched by level", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithErrorLevelFunc(levelByType)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("bind")).SetType(gin.ErrorTypeBind)
+			_ = c.Error(errors.New("private")).SetType(gin.ErrorTypePrivate)
+			_ = c.Error(errors.New("render")).SetType(gin.ErrorTypeRender)
+			_ = c.Error(errors.New("public")).SetType(gin.ErrorTypePublic)
+			c.Status(http.StatusBadRequest)
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.warnings, 1) {
+			assert.Equal(t, "bind", rep.warnings[0][0].(error).Error())
+		}
+		if assert.Len(t, rep.infos, 1) {
+			assert.Equal(t, "private", rep.infos[0][0].(error).Error())
+		}
+		if assert.Len(t, rep.debugs, 1) {
+			assert.Equal(t, "render", rep.debugs[0][0].(error).Error())
+		}
+		if assert.Len(t, rep.errors, 1) {
+			assert.Equal(t, "public", rep.errors[0][0].(error).Error())
+		}
+	})
+
+	t.Run("empty and unknown levels are reported as errors", func(t *testing.T) {
+		for _, level := range []string{"", "fatal"} {
+			m := NewMiddleware(false, false, "", WithDryRun(true), WithErrorLevelFunc(func(*gin.Error) string {
+				return level
+			}))
+			router := newTestRouter(m.Handler())
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			if reports := m.DryRunReports(); assert.Len(t, reports, 1, level) {
+				assert.Equal(t, rollbar.ERR, reports[0].Level, level)
+			}
+		}
+	})
+
+	t.Run("errors are reported at error level by default", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, ""))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("bind")).SetType(gin.ErrorTypeBind)
+			_ = c.Error(errors.New("private")).SetType(gin.ErrorTypePrivate)
+			c.Status(http.StatusBadRequest)
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.errors, 2)
+		assert.Empty(t, rep.warnings)
+		assert.Empty(t, rep.infos)
+	})
+}
+
+func TestWithIgnoredStatusCodes(t *testing.T) {
+	tests := []struct {
+		name               string
+		status             int
+		expectedErrorCalls int
+		expectedPanicCalls int
+	}{
+		{name: "ignored status code is not reported", status: http.StatusNotFound},
+		{
+			name:               "other status codes are reported",
+			status:             http.StatusInternalServerError,
+			expectedErrorCalls: 1,
+			expectedPanicCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithIgnoredStatusCodes(http.StatusNotFound)))
+			router.GET("/error", func(c *gin.Context) {
+				c.AbortWithError(tt.status, errors.New("test error")) //nolint:errcheck
+			})
+			router.GET("/panic", func(c *gin.Context) {
+				c.Status(tt.status)
+				panic("occurs panic")
+			})
+
+			assert.Equal(t, tt.status, performRequest("GET", "/error", router).Code)
+			// the panic is still propagated to the outer recovery
+			assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/panic", router).Code)
+
+			assert.Len(t, rep.errors, tt.expectedErrorCalls)
+			assert.Len(t, rep.criticals, tt.expectedPanicCalls)
+		})
+	}
+}
+
+func TestWithIgnoredPaths(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithIgnoredPaths("/healthz", "/users/:id")))
+	handler := func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	}
+	router.GET("/healthz", handler)
+	router.GET("/users/:id", handler)
+	router.GET("/orders/:id", handler)
+
+	assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/healthz", router).Code)
+	assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/users/42", router).Code)
+	assert.Empty(t, rep.errors)
+	assert.Empty(t, rep.criticals)
+
+	performRequest("GET", "/orders/42", router)
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+}
+
+func TestWithCaptureQueryParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		target   string
+		expected map[string]string
+	}{
+		{
+			name:     "query parameters are captured",
+			enabled:  true,
+			target:   "/search?q=go&page=2",
+			expected: map[string]string{"q": "go", "page": "2"},
+		},
+		{
+			name:     "repeated values are joined and sensitive keys are masked",
+			enabled:  true,
+			target:   "/search?tag=a&tag=b&access_token=abc",
+			expected: map[string]string{"tag": "a,b", "access_token": "********"},
+		},
+		{
+			name:    "query parameters are not captured when disabled",
+			enabled: false,
+			target:  "/search?q=go&page=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithCaptureQueryParams(tt.enabled)))
+			router.GET("/search", func(c *gin.Context) {
+				c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+			})
+
+			performRequest("GET", tt.target, router)
+
+			if !assert.Len(t, rep.errors, 1) {
+				return
+			}
+			query, ok := extraDataOf(t, rep.errors[0])["query"]
+			if tt.expected == nil {
+				assert.False(t, ok)
+				return
+			}
+			assert.Equal(t, tt.expected, query)
+		})
+	}
+}
+
+func TestWithScrubbedHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		option   Option
+		expected http.Header
+	}{
+		{
+			name:   "default headers are masked",
+			option: WithScrubbedHeaders(),
+			expected: http.Header{
+				"Authorization": {"********"},
+				"Cookie":        {"********"},
+				"X-Api-Key":     {"********"},
+				"X-Custom":      {"custom"},
+			},
+		},
+		{
+			name:   "given headers are masked",
+			option: WithScrubbedHeaders("x-custom"),
+			expected: http.Header{
+				"Authorization": {"Bearer secret"},
+				"Cookie":        {"session=secret"},
+				"X-Api-Key":     {"secret"},
+				"X-Custom":      {"********"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.option))
+
+			var original *http.Request
+			router.GET("/", func(c *gin.Context) {
+				original = c.Request
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			r.Header.Set("Cookie", "session=secret")
+			r.Header.Set("X-Api-Key", "secret")
+			r.Header.Set("X-Custom", "custom")
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !assert.Len(t, rep.errors, 1) || !assert.Len(t, rep.criticals, 1) {
+				return
+			}
+			for _, interfaces := range [][]interface{}{rep.errors[0], rep.criticals[0]} {
+				reported, ok := interfaces[1].(*http.Request)
+				if assert.True(t, ok, "interfaces[1] should be *http.Request") {
+					assert.NotSame(t, original, reported)
+					assert.Equal(t, tt.expected, reported.Header)
+				}
+			}
+
+			// the request seen by the handlers is left untouched
+			assert.Equal(t, "Bearer secret", original.Header.Get("Authorization"))
+			assert.Equal(t, "session=secret", original.Header.Get("Cookie"))
+			assert.Equal(t, "secret", original.Header.Get("X-Api-Key"))
+			assert.Equal(t, "custom", original.Header.Get("X-Custom"))
+		})
+	}
+}
+
+func TestWithPersonFromContext(t *testing.T) {
+	personFromContext := func(c *gin.Context) (id, username, email string) {
+		return c.GetString("user_id"), c.GetString("username"), c.GetString("email")
+	}
+
+	t.Run("person is attached when the user is in context", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithPersonFromContext(personFromContext)))
+		router.GET("/", func(c *gin.Context) {
+			c.Set("user_id", "42")
+			c.Set("username", "gopher")
+			c.Set("email", "gopher@example.com")
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		expected := map[string]string{"id": "42", "username": "gopher", "email": "gopher@example.com"}
+		if assert.Len(t, rep.errors, 1) {
+			assert.Equal(t, expected, extraDataOf(t, rep.errors[0])["person"])
+		}
+		if assert.Len(t, rep.criticals, 1) {
+			assert.Equal(t, expected, extraDataOf(t, rep.criticals[0])["person"])
+		}
+	})
+
+	t.Run("person is omitted when the id is empty", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithPersonFromContext(personFromContext)))
+		router.GET("/", func(c *gin.Context) {
+			c.Set("username", "gopher")
+			c.AbortWithError(http.StatusUnauthorized, errors.New("test error")) //nolint:errcheck
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.errors, 1) {
+			assert.NotContains(t, extraDataOf(t, rep.errors[0]), "person")
+		}
+	})
+}
+
+func TestWithRecover(t *testing.T) {
+	handler := func(c *gin.Context) {
+		panic("occurs panic")
+	}
+
+	t.Run("panic is swallowed with a 500 when enabled", func(t *testing.T) {
+		rep := captureReports(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(LogRequests(false, false, "", WithRecover(true)))
+		router.GET("/", handler)
+
+		var w *httptest.ResponseRecorder
+		assert.NotPanics(t, func() {
+			w = performRequest("GET", "/", router)
+		})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Len(t, rep.criticals, 1)
+	})
+
+	t.Run("panic propagates when disabled", func(t *testing.T) {
+		rep := captureReports(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(LogRequests(false, false, "", WithRecover(false)))
+		router.GET("/", handler)
+
+		assert.PanicsWithValue(t, "occurs panic", func() {
+			performRequest("GET", "/", router)
+		})
+		assert.Len(t, rep.criticals, 1)
+	})
+}
+
+func TestWithPanicResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         interface{}
+		expectedBody string
+	}{
+		{
+			name:         "JSON body is written",
+			status:       http.StatusServiceUnavailable,
+			body:         gin.H{"error": "internal error"},
+			expectedBody: `{"error":"internal error"}`,
+		},
+		{
+			name:         "nil body only writes the status",
+			status:       http.StatusServiceUnavailable,
+			expectedBody: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			RollbarCritical = func(interfaces ...interface{}) {
+				// reporting happens before the response is written
+				assert.Equal(t, http.StatusOK, extraDataOf(t, interfaces)["status_code"])
+				rep.criticals = append(rep.criticals, interfaces)
+			}
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(LogRequests(false, false, "", WithRecover(true), WithPanicResponse(tt.status, tt.body)))
+			router.GET("/", func(c *gin.Context) {
+				panic("occurs panic")
+			})
+
+			w := performRequest("GET", "/", router)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+			assert.Len(t, rep.criticals, 1)
+		})
+	}
+}
+
+func TestWithCaptureStack(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "stack is captured when enabled", enabled: true},
+		{name: "stack is not captured when disabled", enabled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithCaptureStack(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			if !assert.Len(t, rep.criticals, 1) {
+				return
+			}
+			stack, ok := extraDataOf(t, rep.criticals[0])["stack"].(string)
+			if tt.enabled {
+				assert.True(t, ok, "stack should be a string")
+				assert.NotEmpty(t, stack)
+			} else {
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
+func TestWithSampleRate(t *testing.T) {
+	tests := []struct {
+		name               string
+		rate               float64
+		expectedErrorCalls int
+	}{
+		{name: "no error is reported with a rate of 0", rate: 0, expectedErrorCalls: 0},
+		{name: "every error is reported with a rate of 1", rate: 1, expectedErrorCalls: 100},
+		{name: "a fraction of errors is reported", rate: 0.5, expectedErrorCalls: 51},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origRandFloat64 := randFloat64
+			randFloat64 = rand.New(rand.NewSource(1)).Float64 //nolint:gosec
+			t.Cleanup(func() { randFloat64 = origRandFloat64 })
+
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithSampleRate(tt.rate)))
+			router.GET("/", func(c *gin.Context) {
+				for range 100 {
+					_ = c.Error(errors.New("test error"))
+				}
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrorCalls)
+			// panics are never sampled
+			assert.Len(t, rep.criticals, 1)
+		})
+	}
+}
+
+func TestWithDedupeWindow(t *testing.T) {
+	clock := fakeClock(t)
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithDedupeWindow(time.Minute)))
+	router.GET("/", func(c *gin.Context) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+	})
+
+	for range 5 {
+		performRequest("GET", "/", router)
+		*clock = clock.Add(time.Second)
+	}
+	if assert.Len(t, rep.errors, 1) {
+		assert.NotContains(t, extraDataOf(t, rep.errors[0]), "occurrences")
+	}
+
+	*clock = clock.Add(time.Minute)
+	performRequest("GET", "/", router)
+	if assert.Len(t, rep.errors, 2) {
+		assert.Equal(t, 5, extraDataOf(t, rep.errors[1])["occurrences"])
+	}
+}
+
+func TestWithSynchronous(t *testing.T) {
+	tests := []struct {
+		name         string
+		synchronous  bool
+		expectedSent bool
+	}{
+		{name: "reports are sent before the middleware returns", synchronous: true, expectedSent: true},
+		{name: "reports are sent asynchronously by default", synchronous: false, expectedSent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pending sync.WaitGroup
+			var sent atomic.Int32
+			release := make(chan struct{})
+			send := func(...interface{}) {
+				pending.Add(1)
+				go func() {
+					defer pending.Done()
+					<-release
+					sent.Add(1)
+				}()
+			}
+
+			captureReports(t)
+			RollbarError, RollbarCritical = send, send
+			origWait := RollbarWait
+			RollbarWait = func() {
+				close(release)
+				pending.Wait()
+			}
+			t.Cleanup(func() { RollbarWait = origWait })
+
+			router := newTestRouter(LogRequests(false, false, "", WithSynchronous(tt.synchronous)))
+			router.GET("/", func(c *gin.Context) {
+				c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Equal(t, tt.expectedSent, sent.Load() == 1)
+			if !tt.synchronous {
+				close(release)
+				pending.Wait()
+			}
+		})
+	}
+}
+
+func TestWithExtraData(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithExtraData(func(c *gin.Context) map[string]interface{} {
+		return map[string]interface{}{
+			"tenant_id": c.GetString("tenant_id"),
+			"endpoint":  "overwritten",
+		}
+	})))
+	router.GET("/", func(c *gin.Context) {
+		c.Set("tenant_id", "acme")
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		extraData := extraDataOf(t, rep.errors[0])
+		assert.Equal(t, "acme", extraData["tenant_id"])
+		assert.Equal(t, "/", extraData["endpoint"])
+	}
+	if assert.Len(t, rep.criticals, 1) {
+		extraData := extraDataOf(t, rep.criticals[0])
+		assert.Equal(t, "acme", extraData["tenant_id"])
+		assert.Equal(t, "/", extraData["endpoint"])
+	}
+}
+
+func TestWithPrivateErrorsAsInfo(t *testing.T) {
+	tests := []struct {
+		name               string
+		enabled            bool
+		expectedErrorCalls int
+		expectedInfoCalls  int
+	}{
+		{
+			name:               "private errors are reported at info level when enabled",
+			enabled:            true,
+			expectedErrorCalls: 1,
+			expectedInfoCalls:  1,
+		},
+		{name: "private errors are reported at error level by default", enabled: false, expectedErrorCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithPrivateErrorsAsInfo(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("private")).SetType(gin.ErrorTypePrivate)
+				_ = c.Error(errors.New("public")).SetType(gin.ErrorTypePublic)
+				c.Status(http.StatusBadRequest)
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrorCalls)
+			if assert.Len(t, rep.infos, tt.expectedInfoCalls) && tt.expectedInfoCalls > 0 {
+				assert.Equal(t, "private", rep.infos[0][0].(error).Error())
+				assert.Equal(t, "public", rep.errors[0][0].(error).Error())
+			}
+		})
+	}
+}
+
+func TestWithClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		remoteAddr string
+		expected   interface{}
+	}{
+		{
+			name:       "forwarded IP is used behind a trusted proxy",
+			enabled:    true,
+			remoteAddr: "192.0.2.1:1234",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "remote IP is used behind an untrusted proxy",
+			enabled:    true,
+			remoteAddr: "198.51.100.9:1234",
+			expected:   "198.51.100.9",
+		},
+		{name: "IP is not captured when disabled", enabled: false, remoteAddr: "192.0.2.1:1234", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithClientIP(tt.enabled)))
+			assert.NoError(t, router.SetTrustedProxies([]string{"192.0.2.1"}))
+			router.GET("/", func(c *gin.Context) {
+				c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", "203.0.113.7")
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expected, extraDataOf(t, rep.errors[0])["ip"])
+			}
+		})
+	}
+}
+
+func TestWithCaptureBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "JSON body is captured",
+			contentType:  "application/json",
+			body:         `{"name":"gopher"}`,
+			expectedBody: `{"name":"gopher"}`,
+		},
+		{
+			name:         "form body is captured",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "name=gopher",
+			expectedBody: "name=gopher",
+		},
+		{
+			name:         "body larger than the cap is truncated",
+			contentType:  "application/json",
+			body:         `{"name":"` + strings.Repeat("a", 64) + `"}`,
+			expectedBody: `{"name":"aaaaaaaaaaaaaaaaaaaaaaa`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithCaptureBody(32)))
+
+			var received string
+			router.POST("/", func(c *gin.Context) {
+				body, err := io.ReadAll(c.Request.Body)
+				assert.NoError(t, err)
+				received = string(body)
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			// the handlers still read the complete body
+			assert.Equal(t, tt.body, received)
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expectedBody, extraDataOf(t, rep.errors[0])["body"])
+			}
+			if assert.Len(t, rep.criticals, 1) {
+				assert.Equal(t, tt.expectedBody, extraDataOf(t, rep.criticals[0])["body"])
+			}
+		})
+	}
+
+	t.Run("body is not captured when disabled", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, ""))
+		router.POST("/", func(c *gin.Context) {
+			c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("name=gopher")))
+
+		if assert.Len(t, rep.errors, 1) {
+			assert.NotContains(t, extraDataOf(t, rep.errors[0]), "body")
+		}
+	})
+}
+
+func TestWithScrubbedBodyFields(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithCaptureBody(1024), WithScrubbedBodyFields("password")))
+	router.POST("/", func(c *gin.Context) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+	})
+
+	body := `{"user":{"name":"gopher","password":"secret"}}`
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.JSONEq(t, `{"user":{"name":"gopher","password":"********"}}`, extraDataOf(t, rep.errors[0])["body"].(string))
+	}
+}
+
+func panickingHandler(c *gin.Context) {
+	panic("occurs panic")
+}
+
+func TestWithPreserveStack(t *testing.T) {
+	captureReports(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	var stack interface{}
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				stack, _ = c.Get(StackKey)
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	})
+	router.Use(LogRequests(false, false, "", WithPreserveStack(true)))
+	router.GET("/", panickingHandler)
+
+	performRequest("GET", "/", router)
+
+	if assert.IsType(t, "", stack) {
+		assert.Contains(t, stack, "ginrollbar/v2.panickingHandler")
+	}
+}
+
+func TestWithAdditionalReporter(t *testing.T) {
+	type report struct {
+		err   error
+		req   *http.Request
+		extra map[string]interface{}
+	}
+
+	t.Run("both sinks receive the reports", func(t *testing.T) {
+		rep := captureReports(t)
+		var additional []report
+		router := newTestRouter(LogRequests(false, false, "", WithAdditionalReporter(
+			func(err error, req *http.Request, extra map[string]interface{}) {
+				additional = append(additional, report{err: err, req: req, extra: extra})
+			},
+		)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.errors, 1)
+		assert.Len(t, rep.criticals, 1)
+		if assert.Len(t, additional, 2) {
+			assert.Equal(t, "test error", additional[0].err.Error())
+			assert.Equal(t, "occurs panic", additional[1].err.Error())
+			for _, r := range additional {
+				assert.Equal(t, "/", r.req.RequestURI)
+				assert.Equal(t, "/", r.extra["endpoint"])
+			}
+		}
+	})
+
+	t.Run("additional reporter runs when the primary panics", func(t *testing.T) {
+		captureReports(t)
+		RollbarError = func(...interface{}) {
+			panic("rollbar failure")
+		}
+		calls := 0
+		router := newTestRouter(LogRequests(false, false, "", WithAdditionalReporter(
+			func(error, *http.Request, map[string]interface{}) {
+				calls++
+			},
+		)))
+		router.GET("/", func(c *gin.Context) {
+			c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("a panicking additional reporter does not break the request", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithAdditionalReporter(
+			func(error, *http.Request, map[string]interface{}) {
+				panic("sink failure")
+			},
+		)))
+		router.GET("/", func(c *gin.Context) {
+			c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+		})
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Len(t, rep.errors, 1)
+	})
+}
+
+func TestWithEnabled(t *testing.T) {
+	tests := []struct {
+		name               string
+		enabled            bool
+		expectedErrorCalls int
+		expectedPanicCalls int
+	}{
+		{name: "nothing is reported when disabled", enabled: false},
+		{name: "errors and panics are reported when enabled", enabled: true, expectedErrorCalls: 1, expectedPanicCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithEnabled(tt.enabled)))
+			router.GET("/error", func(c *gin.Context) {
+				c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+			})
+			router.GET("/panic", func(c *gin.Context) {
+				panic("occurs panic")
+			})
+
+			assert.Equal(t, http.StatusBadRequest, performRequest("GET", "/error", router).Code)
+			assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/panic", router).Code)
+			assert.Len(t, rep.errors, tt.expectedErrorCalls)
+			assert.Len(t, rep.criticals, tt.expectedPanicCalls)
+		})
+	}
+
+	t.Run("panics are still recovered in recover mode when disabled", func(t *testing.T) {
+		rep := captureReports(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(LogRequests(false, false, "", WithEnabled(false), WithRecover(true)))
+		router.GET("/panic", func(c *gin.Context) {
+			panic("occurs panic")
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/panic", router).Code)
+		assert.Empty(t, rep.criticals)
+	})
+}
+
+func TestWithReportErrorTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    gin.ErrorType
+		expected []string
+	}{
+		{
+			name:     "only the masked types are reported",
+			types:    gin.ErrorTypePublic | gin.ErrorTypeBind,
+			expected: []string{"public error", "bind error"},
+		},
+		{name: "every type is reported by default", expected: []string{"private error", "public error", "bind error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithReportErrorTypes(tt.types)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("private error")).SetType(gin.ErrorTypePrivate)
+				_ = c.Error(errors.New("public error")).SetType(gin.ErrorTypePublic)
+				_ = c.Error(errors.New("bind error")).SetType(gin.ErrorTypeBind)
+			})
+
+			performRequest("GET", "/", router)
+
+			var reported []string
+			for _, interfaces := range rep.errors {
+				reported = append(reported, interfaces[0].(error).Error())
+			}
+			assert.Equal(t, tt.expected, reported)
+		})
+	}
+}
+
+func TestWithErrorFilter(t *testing.T) {
+	errValidation := errors.New("validation failed")
+
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithErrorFilter(func(item *gin.Error) bool {
+		return !errors.Is(item.Err, errValidation)
+	})))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("field name: %w", errValidation)).SetType(gin.ErrorTypePublic)
+		_ = c.Error(errors.New("database down")).SetType(gin.ErrorTypePublic)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Equal(t, "database down", rep.errors[0][0].(error).Error())
+	}
+}
+
+func TestWithLastErrorOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected []string
+	}{
+		{name: "only the last error is reported when enabled", enabled: true, expected: []string{"third"}},
+		{name: "every error is reported by default", enabled: false, expected: []string{"first", "second", "third"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithLastErrorOnly(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("first"))
+				_ = c.Error(errors.New("second"))
+				_ = c.Error(errors.New("third"))
+				c.Status(http.StatusBadRequest)
+			})
+
+			performRequest("GET", "/", router)
+
+			var reported []string
+			for _, interfaces := range rep.errors {
+				reported = append(reported, interfaces[0].(error).Error())
+			}
+			assert.Equal(t, tt.expected, reported)
+		})
+	}
+}
+
+func TestWithFingerprintFunc(t *testing.T) {
+	fingerprint := func(c *gin.Context, err error) string {
+		if c.Query("group") == "" {
+			return ""
+		}
+		return c.Query("group") + ":" + err.Error()
+	}
+
+	t.Run("fingerprint is forwarded", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithFingerprintFunc(fingerprint)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/?group=users", router)
+
+		if assert.Len(t, rep.errors, 1) {
+			assert.Equal(t, "users:test error", extraDataOf(t, rep.errors[0])["fingerprint"])
+		}
+		if assert.Len(t, rep.criticals, 1) {
+			assert.Equal(t, "users:occurs panic", extraDataOf(t, rep.criticals[0])["fingerprint"])
+		}
+	})
+
+	t.Run("empty fingerprint is omitted", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithFingerprintFunc(fingerprint)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		for _, interfaces := range append(rep.errors, rep.criticals...) {
+			assert.NotContains(t, extraDataOf(t, interfaces), "fingerprint")
+		}
+		assert.Len(t, rep.errors, 1)
+		assert.Len(t, rep.criticals, 1)
+	})
+}
+
+func TestWithContextPropagation(t *testing.T) {
+	type ctxKey struct{}
+
+	rep := captureReports(t)
+	var contexts []context.Context
+	RollbarErrorWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		contexts = append(contexts, ctx)
+		rep.errors = append(rep.errors, interfaces)
+	}
+	RollbarCriticalWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		contexts = append(contexts, ctx)
+		rep.criticals = append(rep.criticals, interfaces)
+	}
+
+	router := newTestRouter(LogRequests(false, false, "", WithContextPropagation(true)))
+	router.GET("/", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ctxKey{}, "value"))
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	if assert.Len(t, contexts, 2) {
+		for _, ctx := range contexts {
+			assert.Equal(t, "value", ctx.Value(ctxKey{}))
+		}
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	rep := captureReports(t)
+	logger := &fakeLogger{}
+	router := newTestRouter(LogRequests(false, false, "", WithLogger(logger)))
+	router.GET("/users", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/users?page=2", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	assert.Equal(t, []string{
+		"ginrollbar: reporting error on /users?page=2: test error",
+		"ginrollbar: reporting critical on /users?page=2: occurs panic",
+	}, logger.lines)
+}
+
+func TestWithStackSkip(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{name: "configured skip is passed to rollbar", opts: []Option{WithStackSkip(5)}, expected: 5},
+		{name: "skip defaults to 3", expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.criticals, 1) {
+				assert.Equal(t, tt.expected, rep.criticals[0][2])
+			}
+		})
+	}
+}
+
+func TestWithCapturedHeaders(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "",
+		WithCapturedHeaders("user-agent", "X-REQUEST-ID", "X-Tenant", "Authorization"),
+		WithScrubbedHeaders(),
+	))
+	router.GET("/", func(c *gin.Context) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Other", "ignored")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Equal(t, map[string]string{
+			"User-Agent":    "test-agent",
+			"X-Request-Id":  "abc",
+			"Authorization": "********",
+		}, extraDataOf(t, rep.errors[0])["headers"])
+	}
+}
+
+func TestWithMinStatusCode(t *testing.T) {
+	tests := []struct {
+		name               string
+		status             int
+		expectedErrorCalls int
+	}{
+		{name: "errors below the threshold are not reported", status: http.StatusUnprocessableEntity, expectedErrorCalls: 0},
+		{name: "errors at the threshold are reported", status: http.StatusInternalServerError, expectedErrorCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithMinStatusCode(http.StatusInternalServerError)))
+			router.GET("/error", func(c *gin.Context) {
+				c.AbortWithError(tt.status, errors.New("test error")) //nolint:errcheck
+			})
+			router.GET("/panic", func(c *gin.Context) {
+				c.Status(tt.status)
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/error", router)
+			performRequest("GET", "/panic", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrorCalls)
+			assert.Len(t, rep.criticals, 1, "panics are always reported")
+		})
+	}
+}
+
+func TestWithTitleFunc(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithTitleFunc(func(c *gin.Context, err error) string {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return c.FullPath() + ": truncated payload"
+		}
+		return ""
+	})))
+	router.GET("/upload", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("reading: %w", io.ErrUnexpectedEOF))
+		_ = c.Error(errors.New("test error"))
+		c.Status(http.StatusBadRequest)
+	})
+
+	performRequest("GET", "/upload", router)
+
+	if assert.Len(t, rep.errors, 2) {
+		assert.Equal(t, "/upload: truncated payload", extraDataOf(t, rep.errors[0])["title"])
+		assert.NotContains(t, extraDataOf(t, rep.errors[1]), "title")
+	}
+}
+
+func TestWithUnwrapErrors(t *testing.T) {
+	cause := errors.New("connection refused")
+	wrapped := fmt.Errorf("loading user: %w", fmt.Errorf("querying database: %w", cause))
+
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithUnwrapErrors(true)))
+	router.GET("/", func(c *gin.Context) {
+		c.AbortWithError(http.StatusInternalServerError, wrapped) //nolint:errcheck
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Same(t, wrapped, rep.errors[0][0], "the original error is still the primary argument")
+		assert.Equal(t, []string{
+			"loading user: querying database: connection refused",
+			"querying database: connection refused",
+			"connection refused",
+		}, extraDataOf(t, rep.errors[0])["cause_chain"])
+	}
+}
+
+func TestWithCapturedResponseHeaders(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithCapturedResponseHeaders("x-cache", "X-Correlation-Id")))
+	router.GET("/", func(c *gin.Context) {
+		c.Header("X-Cache", "MISS")
+		c.Header("X-Other", "ignored")
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		assert.Equal(t, map[string]string{"X-Cache": "MISS"}, extraDataOf(t, interfaces)["response_headers"])
+	}
+}
+
+func TestWithEnvironmentAndCodeVersion(t *testing.T) {
+	tests := []struct {
+		name                string
+		opts                []Option
+		expectedEnvironment interface{}
+		expectedCodeVersion interface{}
+	}{
+		{
+			name:                "fields are included when set",
+			opts:                []Option{WithEnvironment("admin"), WithCodeVersion("v1.2.3")},
+			expectedEnvironment: "admin",
+			expectedCodeVersion: "v1.2.3",
+		},
+		{
+			name: "fields are omitted when empty",
+			opts: []Option{WithEnvironment(""), WithCodeVersion("")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, 1)
+			assert.Len(t, rep.criticals, 1)
+			for _, interfaces := range append(rep.errors, rep.criticals...) {
+				extraData := extraDataOf(t, interfaces)
+				assert.Equal(t, tt.expectedEnvironment, extraData["environment"])
+				assert.Equal(t, tt.expectedCodeVersion, extraData["code_version"])
+			}
+		})
+	}
+}
+
+func TestWithDelegateRecovery(t *testing.T) {
+	t.Run("panic reaches gin.Recovery", func(t *testing.T) {
+		rep := captureReports(t)
+		router := gin.New()
+		router.Use(gin.RecoveryWithWriter(io.Discard))
+		router.Use(LogRequests(false, false, "", WithRecover(true), WithDelegateRecovery(true)))
+		router.GET("/", panickingHandler)
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Len(t, rep.criticals, 1)
+	})
+
+	t.Run("gin.CustomRecovery receives the original value", func(t *testing.T) {
+		rep := captureReports(t)
+		var recovered interface{}
+		router := gin.New()
+		router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+			recovered = err
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+		}))
+		router.Use(LogRequests(false, false, "", WithRecover(true), WithDelegateRecovery(true)))
+		router.GET("/", panickingHandler)
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "occurs panic", recovered)
+		assert.Len(t, rep.criticals, 1)
+	})
+}
+
+func TestWithCombinePanicContext(t *testing.T) {
+	tests := []struct {
+		name                string
+		enabled             bool
+		expectedErrors      int
+		expectedPriorErrors interface{}
+	}{
+		{
+			name:                "errors are folded into the panic report",
+			enabled:             true,
+			expectedErrors:      0,
+			expectedPriorErrors: []string{"first error", "second error"},
+		},
+		{
+			name:           "errors are reported separately by default",
+			enabled:        false,
+			expectedErrors: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithCombinePanicContext(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("first error"))
+				_ = c.Error(errors.New("second error"))
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			assert.Len(t, rep.criticals, 1)
+			assert.Equal(t, tt.expectedPriorErrors, extraDataOf(t, rep.criticals[0])["prior_errors"])
+		})
+	}
+
+	t.Run("errors are reported when nothing panics", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithCombinePanicContext(true)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.errors, 1)
+		assert.Empty(t, rep.criticals)
+	})
+}
+
+func TestWithAllowOverrideReserved(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             []Option
+		expectedEndpoint interface{}
+	}{
+		{
+			name:             "built-in keys are preserved by default",
+			expectedEndpoint: "/",
+		},
+		{
+			name:             "built-in keys are overridden when allowed",
+			opts:             []Option{WithAllowOverrideReserved(true)},
+			expectedEndpoint: "custom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			opts := append([]Option{WithExtraData(func(c *gin.Context) map[string]interface{} {
+				return map[string]interface{}{"endpoint": "custom"}
+			})}, tt.opts...)
+			router := newTestRouter(LogRequests(false, false, "", opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, 1)
+			assert.Equal(t, tt.expectedEndpoint, extraDataOf(t, rep.errors[0])["endpoint"])
+		})
+	}
+}
+
+func TestWithPanicLevelFunc(t *testing.T) {
+	panicLevel := func(recovered interface{}) string {
+		if recovered == context.Canceled {
+			return "error"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name              string
+		recovered         interface{}
+		expectedErrors    int
+		expectedCriticals int
+	}{
+		{
+			name:           "panic routed to error level",
+			recovered:      context.Canceled,
+			expectedErrors: 1,
+		},
+		{
+			name:              "panic left at critical",
+			recovered:         "occurs panic",
+			expectedCriticals: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithPanicLevelFunc(panicLevel)))
+			router.GET("/", func(c *gin.Context) {
+				panic(tt.recovered)
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			assert.Len(t, rep.criticals, tt.expectedCriticals)
+		})
+	}
+}
+
+func TestWithStructuredMeta(t *testing.T) {
+	meta := map[string]string{"user": "42"}
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected interface{}
+	}{
+		{name: "meta is stringified by default", enabled: false, expected: "map[user:42]"},
+		{name: "meta keeps its structure", enabled: true, expected: meta},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithStructuredMeta(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error")).SetMeta(meta)
+			})
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.Equal(t, tt.expected, extraDataOf(t, rep.errors[0])["meta"])
+			}
+		})
+	}
+}
+
+func TestWithKeyNames(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "X-Request-Id", WithKeyNames(map[string]string{
+		"endpoint":   "url",
+		"request_id": "correlation_id",
+		"unknown":    "ignored",
+		"title":      "ignored",
+	})))
+	router.GET("/", func(c *gin.Context) {
+		c.Header("X-Request-Id", "abc")
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		extraData := extraDataOf(t, interfaces)
+		assert.Equal(t, "/", extraData["url"])
+		assert.Equal(t, "abc", extraData["correlation_id"])
+		assert.Equal(t, "GET", extraData["method"])
+		assert.NotContains(t, extraData, "endpoint")
+		assert.NotContains(t, extraData, "request_id")
+		assert.NotContains(t, extraData, "ignored")
+	}
+}
+
+// fakeTransport records the items a rollbar.Client sends instead of calling the API
+type fakeTransport struct {
+	rollbar.Transport
+	mu     sync.Mutex
+	levels []string
+}
+
+func (f *fakeTransport) Send(body map[string]interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data := body["data"].(map[string]interface{})
+	f.levels = append(f.levels, data["level"].(string))
+	return nil
+}
+
+func (f *fakeTransport) IsMessageFiltered(interface{}, string) bool {
+	return false
+}
+
+func (f *fakeTransport) Wait() {}
+
+func TestWithClient(t *testing.T) {
+	rep := captureReports(t)
+	transport := &fakeTransport{}
+	client := rollbar.NewSync("token", "test", "", "", "")
+	client.Transport = transport
+	router := newTestRouter(LogRequests(false, false, "", WithClient(client), WithSynchronous(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	assert.Equal(t, []string{"error", "critical"}, transport.levels)
+	assert.Empty(t, rep.errors)
+	assert.Empty(t, rep.criticals)
+}
+
+func TestWithTags(t *testing.T) {
+	rep := captureReports(t)
+	tags := map[string]string{"service": "payments"}
+	router := newTestRouter(LogRequests(false, false, "", WithTags(tags)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+	tags["service"] = "changed"
+
+	performRequest("GET", "/", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 1)
+	for _, interfaces := range append(rep.errors, rep.criticals...) {
+		assert.Equal(t, map[string]string{"service": "payments"}, extraDataOf(t, interfaces)["tags"])
+	}
+}
+
+func TestWithReportCounter(t *testing.T) {
+	captureReports(t)
+	counts := map[string]int{}
+	router := newTestRouter(LogRequests(false, false, "", WithReportCounter(func(kind string) {
+		counts[kind]++
+	})))
+	router.GET("/errors", func(c *gin.Context) {
+		_ = c.Error(errors.New("first error"))
+		_ = c.Error(errors.New("second error"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+	router.GET("/ok", func(c *gin.Context) {})
+
+	performRequest("GET", "/errors", router)
+	performRequest("GET", "/panic", router)
+	performRequest("GET", "/ok", router)
+
+	assert.Equal(t, map[string]int{"error": 3, "panic": 1}, counts)
+}
+
+func TestWithReportCounterSkipsUnforwardedReports(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		setup func(t *testing.T)
+	}{
+		{name: "dry run", opts: []Option{WithDryRun(true)}},
+		{
+			name: "stderr fallback",
+			opts: []Option{WithStderrFallback(true)},
+			setup: func(t *testing.T) {
+				rollbarToken, fallbackWriter := RollbarToken, FallbackWriter
+				t.Cleanup(func() {
+					RollbarToken, FallbackWriter = rollbarToken, fallbackWriter
+				})
+				RollbarToken = func() string { return "" }
+				FallbackWriter = io.Discard
+			},
+		},
+		{
+			name: "panicking rollbar call",
+			setup: func(t *testing.T) {
+				RollbarError = func(interfaces ...interface{}) {
+					panic("rollbar failure")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureReports(t)
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			var counted int
+			opts := append([]Option{WithReportCounter(func(string) { counted++ })}, tt.opts...)
+			router := newTestRouter(LogRequests(false, false, "", opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Zero(t, counted)
+		})
+	}
+
+	t.Run("full async queue", func(t *testing.T) {
+		captureReports(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		var sent int
+		RollbarError = func(interfaces ...interface{}) {
+			if sent == 0 {
+				close(started)
+				<-release
+			}
+			sent++
+		}
+		var counted int
+		router := newTestRouter(LogRequests(false, false, "",
+			WithAsyncQueue(1),
+			WithReportCounter(func(string) { counted++ }),
+		))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+		<-started
+		performRequest("GET", "/", router)
+		performRequest("GET", "/", router)
+		close(release)
+
+		assert.NoError(t, Shutdown(context.Background()))
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, 2, counted, "the dropped report is not counted")
+	})
+}
+
+func TestWithTimeoutLevel(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              []Option
+		cancel            bool
+		expectedErrors    int
+		expectedWarnings  int
+		expectedCriticals int
+		expectedTimeout   interface{}
+	}{
+		{
+			name:              "cancelled requests are tagged",
+			cancel:            true,
+			expectedErrors:    1,
+			expectedCriticals: 1,
+			expectedTimeout:   true,
+		},
+		{
+			name:             "cancelled requests are routed to the timeout level",
+			opts:             []Option{WithTimeoutLevel("warning")},
+			cancel:           true,
+			expectedWarnings: 2,
+			expectedTimeout:  true,
+		},
+		{
+			name:              "other requests are not",
+			opts:              []Option{WithTimeoutLevel("warning")},
+			expectedErrors:    1,
+			expectedCriticals: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+			req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			assert.Len(t, rep.warnings, tt.expectedWarnings)
+			assert.Len(t, rep.criticals, tt.expectedCriticals)
+			for _, interfaces := range append(append(rep.errors, rep.warnings...), rep.criticals...) {
+				assert.Equal(t, tt.expectedTimeout, extraDataOf(t, interfaces)["timeout"])
+			}
+		})
+	}
+}
+
+func TestWithoutRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		enabled         bool
+		expectedRequest bool
+	}{
+		{name: "request is passed by default", enabled: false, expectedRequest: true},
+		{name: "request is dropped", enabled: true, expectedRequest: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithoutRequest(tt.enabled)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, 1)
+			assert.Len(t, rep.criticals, 1)
+			for _, interfaces := range append(rep.errors, rep.criticals...) {
+				hasRequest := false
+				for _, arg := range interfaces {
+					if _, ok := arg.(*http.Request); ok {
+						hasRequest = true
+					}
+				}
+				assert.Equal(t, tt.expectedRequest, hasRequest)
+				assert.Equal(t, "/", extraDataOf(t, interfaces)["endpoint"])
+			}
+		})
+	}
+}
+
+func TestWithBatchErrors(t *testing.T) {
+	tests := []struct {
+		name                     string
+		opts                     []Option
+		expectedAdditionalErrors interface{}
+	}{
+		{
+			name:                     "errors are batched",
+			opts:                     []Option{WithBatchErrors(true)},
+			expectedAdditionalErrors: []string{"second error", "third error"},
+		},
+		{
+			name: "filtered errors are left out",
+			opts: []Option{WithBatchErrors(true), WithErrorFilter(func(item *gin.Error) bool {
+				return item.Error() != "second error"
+			})},
+			expectedAdditionalErrors: []string{"third error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("first error"))
+				_ = c.Error(errors.New("second error"))
+				_ = c.Error(errors.New("third error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.errors, 1) {
+				assert.EqualError(t, rep.errors[0][0].(error), "first error")
+				assert.Equal(t, tt.expectedAdditionalErrors, extraDataOf(t, rep.errors[0])["additional_errors"])
+			}
+		})
+	}
+
+	t.Run("single errors have no list", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithBatchErrors(true)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.errors, 1) {
+			assert.NotContains(t, extraDataOf(t, rep.errors[0]), "additional_errors")
+		}
+	})
+}
+
+func TestWithStatusLevelFunc(t *testing.T) {
+	statusLevel := func(status int) string {
+		if status >= 400 && status < 500 {
+			return "warning"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name             string
+		status           int
+		expectedErrors   int
+		expectedWarnings int
+	}{
+		{name: "4xx goes to warning", status: http.StatusNotFound, expectedWarnings: 1},
+		{name: "5xx goes to error", status: http.StatusInternalServerError, expectedErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithStatusLevelFunc(statusLevel)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.AbortWithError(tt.status, errors.New("test error"))
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			assert.Len(t, rep.warnings, tt.expectedWarnings)
+		})
+	}
+}
+
+func TestWithRuntimeStats(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithRuntimeStats(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.criticals, 1) {
+		stats, ok := extraDataOf(t, rep.criticals[0])["runtime"].(map[string]interface{})
+		if assert.True(t, ok) {
+			assert.Greater(t, stats["goroutines"], 0)
+			assert.Greater(t, stats["alloc"], uint64(0))
+			assert.Greater(t, stats["sys"], uint64(0))
+		}
+	}
+	if assert.Len(t, rep.errors, 1) {
+		assert.NotContains(t, extraDataOf(t, rep.errors[0]), "runtime")
+	}
+}
+
+func TestWithPanicHook(t *testing.T) {
+	type panicValue struct{ code int }
+
+	t.Run("hook receives the original value", func(t *testing.T) {
+		rep := captureReports(t)
+		var hooked interface{}
+		router := newTestRouter(LogRequests(false, false, "", WithPanicHook(func(c *gin.Context, recovered interface{}) {
+			assert.Len(t, rep.criticals, 1)
+			hooked = recovered
+		})))
+		router.GET("/", func(c *gin.Context) {
+			panic(panicValue{code: 42})
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Equal(t, panicValue{code: 42}, hooked)
+	})
+
+	t.Run("panicking hook does not lose the original panic", func(t *testing.T) {
+		captureReports(t)
+		logger := &fakeLogger{}
+		var recovered interface{}
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			defer func() {
+				recovered = recover()
+			}()
+			c.Next()
+		})
+		hook := func(c *gin.Context, recovered interface{}) {
+			panic("hook panic")
+		}
+		router.Use(LogRequests(false, false, "", WithLogger(logger), WithPanicHook(hook)))
+		router.GET("/", func(c *gin.Context) {
+			panic(panicValue{code: 42})
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Equal(t, panicValue{code: 42}, recovered)
+		assert.Contains(t, logger.lines, "ginrollbar: panic hook panicked: hook panic")
+	})
+}
+
+func TestWithStderrFallback(t *testing.T) {
+	tests := []struct {
+		name              string
+		enabled           bool
+		token             string
+		expectedFallback  bool
+		expectedCriticals int
+	}{
+		{name: "reports are written without token", enabled: true, token: "", expectedFallback: true},
+		{name: "reports are sent with a token", enabled: true, token: "token", expectedCriticals: 1},
+		{name: "reports are sent by default", enabled: false, token: "", expectedCriticals: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			rollbarToken, fallbackWriter := RollbarToken, FallbackWriter
+			t.Cleanup(func() {
+				RollbarToken, FallbackWriter = rollbarToken, fallbackWriter
+			})
+			var buf strings.Builder
+			RollbarToken = func() string { return tt.token }
+			FallbackWriter = &buf
+
+			router := newTestRouter(LogRequests(false, false, "", WithStderrFallback(tt.enabled)))
+			router.GET("/", panickingHandler)
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.criticals, tt.expectedCriticals)
+			if tt.expectedFallback {
+				assert.Contains(t, buf.String(), "ginrollbar: critical: occurs panic")
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestWithContextKeys(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithContextKeys("tenant", "callback", "missing")))
+	router.GET("/", func(c *gin.Context) {
+		c.Set("tenant", "acme")
+		c.Set("callback", func() {})
+		c.Set("secret", "hidden")
+		_ = c.Error(errors.New("test error"))
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		values, ok := extraDataOf(t, rep.errors[0])["context"].(map[string]interface{})
+		if assert.True(t, ok) {
+			assert.Equal(t, []string{"callback", "tenant"}, keysOf(values))
+			assert.Equal(t, "acme", values["tenant"])
+			assert.IsType(t, "", values["callback"])
+		}
+	}
+}
+
+func TestWithRemoteAddr(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithRemoteAddr(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if assert.Len(t, rep.errors, 1) {
+		extraData := extraDataOf(t, rep.errors[0])
+		assert.Equal(t, "203.0.113.7", extraData["client_ip"])
+		assert.Equal(t, "10.0.0.1:4321", extraData["remote_addr"])
+	}
+}
+
+func TestWithShutdownGuard(t *testing.T) {
+	rep := captureReports(t)
+	var shuttingDown atomic.Bool
+	router := newTestRouter(LogRequests(false, false, "", WithShutdownGuard(shuttingDown.Load)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+		panic("occurs panic")
+	})
+
+	performRequest("GET", "/", router)
+	shuttingDown.Store(true)
+	performRequest("GET", "/", router)
+
+	assert.Len(t, rep.errors, 1)
+	assert.Len(t, rep.criticals, 2)
+}
+
+func TestWithProtocolInfo(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		proto         string
+		expectedProto string
+		expectedTLS   bool
+	}{
+		{name: "plain HTTP/1.1", target: "http://example.com/", proto: "HTTP/1.1", expectedProto: "HTTP/1.1"},
+		{
+			name:          "HTTP/2 over TLS",
+			target:        "https://example.com/",
+			proto:         "HTTP/2.0",
+			expectedProto: "HTTP/2.0",
+			expectedTLS:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithProtocolInfo(true)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Proto = tt.proto
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				assert.Equal(t, tt.expectedProto, extraData["proto"])
+				assert.Equal(t, tt.expectedTLS, extraData["tls"])
+			}
+		})
+	}
+}
+
+func TestWithRouteOverrides(t *testing.T) {
+	rep := captureReports(t)
+	overrides := map[string]Option{
+		"/api/users/:id": WithCaptureBody(1024),
+	}
+	middleware := LogRequests(false, false, "", WithScrubbedBodyFields("password"), WithRouteOverrides(overrides))
+	router := newTestRouter(middleware)
+	handler := func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	}
+	router.POST("/api/users/:id", handler)
+	router.POST("/other", handler)
+
+	for _, target := range []string{"/api/users/42", "/other"} {
+		req := httptest.NewRequest("POST", target, strings.NewReader(`{"password":"hunter2"}`))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if assert.Len(t, rep.errors, 2) {
+		assert.Equal(t, `{"password":"********"}`, extraDataOf(t, rep.errors[0])["body"])
+		assert.NotContains(t, extraDataOf(t, rep.errors[1]), "body")
+	}
+}
+
+func TestWithClientDisconnectLevel(t *testing.T) {
+	brokenPipe := fmt.Errorf("write response: %w", syscall.EPIPE)
+
+	tests := []struct {
+		name             string
+		opts             []Option
+		err              error
+		expectedInfos    int
+		expectedWarnings int
+		expectedErrors   int
+		expectedTagged   interface{}
+	}{
+		{name: "broken pipes go to info by default", err: brokenPipe, expectedInfos: 1, expectedTagged: true},
+		{
+			name:             "broken pipes go to the configured level",
+			opts:             []Option{WithClientDisconnectLevel("warning")},
+			err:              brokenPipe,
+			expectedWarnings: 1,
+			expectedTagged:   true,
+		},
+		{name: "other errors are untouched", err: errors.New("test error"), expectedErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(tt.err)
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.infos, tt.expectedInfos)
+			assert.Len(t, rep.warnings, tt.expectedWarnings)
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			for _, interfaces := range append(append(rep.infos, rep.warnings...), rep.errors...) {
+				assert.Equal(t, tt.expectedTagged, extraDataOf(t, interfaces)["client_disconnect"])
+			}
+		})
+	}
+}
+
+func TestWithTraceContext(t *testing.T) {
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{
+			0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6,
+			0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36,
+		},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name            string
+		ctx             context.Context
+		expectedTraceID interface{}
+		expectedSpanID  interface{}
+	}{
+		{
+			name:            "ids of the span are attached",
+			ctx:             trace.ContextWithSpanContext(context.Background(), span),
+			expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectedSpanID:  "00f067aa0ba902b7",
+		},
+		{
+			name: "ids are omitted without span",
+			ctx:  context.Background(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithTraceContext(true)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil).WithContext(tt.ctx)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				assert.Equal(t, tt.expectedTraceID, extraData["trace_id"])
+				assert.Equal(t, tt.expectedSpanID, extraData["span_id"])
+			}
+		})
+	}
+}
+
+func TestWithMetaSerializer(t *testing.T) {
+	type orderMeta struct {
+		OrderID string
+		Items   int
+	}
+
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithMetaSerializer(func(meta interface{}) interface{} {
+		order, ok := meta.(orderMeta)
+		if !ok {
+			return nil
+		}
+		return map[string]interface{}{"order_id": order.OrderID}
+	})))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error")).SetMeta(orderMeta{OrderID: "A-1", Items: 3})
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Equal(t, map[string]interface{}{"order_id": "A-1"}, extraDataOf(t, rep.errors[0])["meta"])
+	}
+}
+
+func TestWithPanicStatusFunc(t *testing.T) {
+	errNotFound := errors.New("not found")
+	panicStatus := func(recovered interface{}) int {
+		if recovered == errNotFound {
+			return http.StatusNotFound
+		}
+		return 0
+	}
+
+	tests := []struct {
+		name           string
+		recovered      interface{}
+		expectedStatus int
+	}{
+		{name: "sentinel panic yields 404", recovered: errNotFound, expectedStatus: http.StatusNotFound},
+		{name: "generic panic yields 500", recovered: "occurs panic", expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithRecover(true), WithPanicStatusFunc(panicStatus)))
+			router.GET("/", func(c *gin.Context) {
+				panic(tt.recovered)
+			})
+
+			w := performRequest("GET", "/", router)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Len(t, rep.criticals, 1)
+		})
+	}
+}
+
+func TestWithRefererOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "headers are captured",
+			headers:  map[string]string{"Referer": "https://example.com/form", "Origin": "https://example.com"},
+			expected: map[string]interface{}{"referer": "https://example.com/form", "origin": "https://example.com"},
+		},
+		{
+			name:     "empty headers are omitted",
+			headers:  map[string]string{"Origin": "https://example.com"},
+			expected: map[string]interface{}{"origin": "https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithRefererOrigin(true)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				for _, key := range []string{"referer", "origin"} {
+					value, ok := extraData[key]
+					assert.Equal(t, tt.expected[key], value)
+					_, expected := tt.expected[key]
+					assert.Equal(t, expected, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestWithSkipSuccessfulResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		expectedErrors int
+	}{
+		{name: "2xx errors are suppressed", status: http.StatusOK, expectedErrors: 0},
+		{name: "other errors are reported", status: http.StatusBadRequest, expectedErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithSkipSuccessfulResponses(true)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+				c.Status(tt.status)
+			})
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.errors, tt.expectedErrors)
+		})
+	}
+
+	t.Run("panics are reported", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithSkipSuccessfulResponses(true)))
+		router.GET("/", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Len(t, rep.criticals, 1)
+	})
+}
+
+func TestWithMaxExtraDataBytes(t *testing.T) {
+	tests := []struct {
+		name              string
+		body              string
+		expectedTruncated interface{}
+	}{
+		{name: "oversized body is dropped", body: strings.Repeat("a", 4096), expectedTruncated: true},
+		{name: "small payload is untouched", body: "small"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithCaptureBody(8192), WithMaxExtraDataBytes(1024)))
+			router.POST("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(tt.body)))
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				assert.Equal(t, tt.expectedTruncated, extraData["truncated"])
+				assert.Equal(t, "/", extraData["endpoint"])
+				if tt.expectedTruncated == nil {
+					assert.Equal(t, tt.body, extraData["body"])
+				} else {
+					assert.NotContains(t, extraData, "body")
+				}
+			}
+		})
+	}
+}
+
+func TestWithBefore(t *testing.T) {
+	before := func(c *gin.Context, kind string, err error) (bool, map[string]interface{}) {
+		if kind == "error" {
+			return err.Error() != "ignored error", nil
+		}
+		return true, map[string]interface{}{"tenant": "acme", "endpoint": "overridden"}
+	}
+
+	t.Run("cancels an error report", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithBefore(before)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("ignored error"))
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.errors, 1) {
+			assert.Contains(t, rep.errors[0], errors.New("test error"))
+		}
+	})
+
+	t.Run("enriches a panic report", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithBefore(before)))
+		router.GET("/", panickingHandler)
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.criticals, 1) {
+			extraData := extraDataOf(t, rep.criticals[0])
+			assert.Equal(t, "acme", extraData["tenant"])
+			assert.Equal(t, "/", extraData["endpoint"])
+		}
+	})
+}
+
+func TestWithStripQuery(t *testing.T) {
+	tests := []struct {
+		name             string
+		stripQuery       bool
+		expectedEndpoint string
+	}{
+		{name: "query is stripped", stripQuery: true, expectedEndpoint: "/foo"},
+		{name: "query is kept by default", expectedEndpoint: "/foo?x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithStripQuery(tt.stripQuery), WithCaptureQueryParams(true)))
+			router.GET("/foo", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			performRequest("GET", "/foo?x=1", router)
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				assert.Equal(t, tt.expectedEndpoint, extraData["endpoint"])
+				assert.Equal(t, map[string]string{"x": "1"}, extraData["query"])
+			}
+		})
+	}
+}
+
+func TestWithRollbarAndLoggerFields(t *testing.T) {
+	rep := captureReports(t)
+	logger := &fakeLogger{}
+	router := newTestRouter(LogRequests(false, false, "",
+		WithLogger(logger),
+		WithExtraData(func(c *gin.Context) map[string]interface{} {
+			return map[string]interface{}{"email": "user@example.com"}
+		}),
+		WithRollbarFields("endpoint", "method"),
+		WithLoggerFields("method", "email"),
+	))
+	router.GET("/users", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+
+	performRequest("GET", "/users", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		assert.Equal(t, map[string]interface{}{
+			"endpoint": "/users",
+			"method":   "GET",
+		}, extraDataOf(t, rep.errors[0]))
+	}
+	assert.Equal(t, []string{
+		"ginrollbar: reporting error on /users: test error method=GET email=user@example.com",
+	}, logger.lines)
+}
+
+func TestWithPanicErrorFunc(t *testing.T) {
+	tests := []struct {
+		name            string
+		fn              func(interface{}) error
+		expectedMessage string
+	}{
+		{
+			name: "custom error is reported",
+			fn: func(recovered interface{}) error {
+				return fmt.Errorf("panic: %v", recovered)
+			},
+			expectedMessage: "panic: occurs panic",
+		},
+		{
+			name:            "nil keeps the default error",
+			fn:              func(interface{}) error { return nil },
+			expectedMessage: "occurs panic",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithPanicErrorFunc(tt.fn)))
+			router.GET("/", panickingHandler)
+
+			performRequest("GET", "/", router)
+
+			if assert.Len(t, rep.criticals, 1) {
+				assert.Equal(t, tt.expectedMessage, rep.criticals[0][0].(error).Error())
+			}
+		})
+	}
+}
+
+func TestWithUserAgentParsing(t *testing.T) {
+	const ua = "curl/8.4.0"
+	tests := []struct {
+		name     string
+		ua       string
+		expected map[string]interface{}
+	}{
+		{
+			name: "parsed fields are added with the raw user agent",
+			ua:   ua,
+			expected: map[string]interface{}{
+				"user_agent": ua, "browser": "curl 8", "os": "unknown", "device": "unknown",
+			},
+		},
+		{name: "empty user agent adds nothing", expected: map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			router := newTestRouter(LogRequests(false, false, "", WithUserAgentParsing(true)))
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.ua)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if assert.Len(t, rep.errors, 1) {
+				extraData := extraDataOf(t, rep.errors[0])
+				parsed := map[string]interface{}{}
+				for _, key := range []string{"user_agent", "browser", "os", "device"} {
+					if value, ok := extraData[key]; ok {
+						parsed[key] = value
+					}
+				}
+				assert.Equal(t, tt.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestWithSuppressInTestMode(t *testing.T) {
+	tests := []struct {
+		name              string
+		mode              string
+		suppress          bool
+		expectedCriticals int
+	}{
+		{name: "rollbar is not called in test mode", mode: gin.TestMode, suppress: true, expectedCriticals: 0},
+		{name: "rollbar is called in release mode", mode: gin.ReleaseMode, suppress: true, expectedCriticals: 1},
+		{name: "rollbar is called when disabled", mode: gin.TestMode, expectedCriticals: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			var counted []string
+			router := newTestRouter(LogRequests(false, false, "",
+				WithSuppressInTestMode(tt.suppress),
+				WithReportCounter(func(kind string) {
+					counted = append(counted, kind)
+				}),
+			))
+			router.GET("/", panickingHandler)
+			gin.SetMode(tt.mode)
+			t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+			performRequest("GET", "/", router)
+
+			assert.Len(t, rep.criticals, tt.expectedCriticals)
+			assert.Len(t, counted, tt.expectedCriticals, "suppressed reports are not counted")
+		})
+	}
+}
+
+func TestWithServerHost(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithServerHost(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+
+	performRequest("GET", "/", router)
+
+	if assert.Len(t, rep.errors, 1) {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		assert.Equal(t, hostname, extraDataOf(t, rep.errors[0])["server_host"])
+	}
+}
+
+func TestResolveHostname(t *testing.T) {
+	assert.Equal(t, "web-1", resolveHostname(func() (string, error) { return "web-1", nil }))
+	assert.Equal(t, "unknown", resolveHostname(func() (string, error) { return "", errors.New("no hostname") }))
+}
+
+func TestWithCookieNames(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithCookieNames(true)))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "secret-session"})
+	req.AddCookie(&http.Cookie{Name: "csrf", Value: "secret-csrf"})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if assert.Len(t, rep.errors, 1) {
+		extraData := extraDataOf(t, rep.errors[0])
+		assert.Equal(t, []string{"session", "csrf"}, extraData["cookie_names"])
+		assert.NotContains(t, fmt.Sprint(extraData), "secret")
+	}
+}
+
+func TestWithAfterReport(t *testing.T) {
+	type call struct {
+		kind string
+		err  string
+	}
+
+	t.Run("fires once per report before the re-panic", func(t *testing.T) {
+		rep := captureReports(t)
+		var calls []call
+		router := newTestRouter(LogRequests(false, false, "", WithAfterReport(func(c *gin.Context, kind string, err error) {
+			assert.Equal(t, len(calls)+1, len(rep.errors)+len(rep.criticals), "hook runs after the rollbar call")
+			calls = append(calls, call{kind: kind, err: err.Error()})
+		})))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		w := performRequest("GET", "/", router)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, []call{
+			{kind: "error", err: "test error"},
+			{kind: "panic", err: "occurs panic"},
+		}, calls)
+	})
+
+	t.Run("does not fire when the rollbar call panics", func(t *testing.T) {
+		captureReports(t)
+		RollbarError = func(...interface{}) {
+			panic("rollbar failure")
+		}
+		var calls int
+		router := newTestRouter(LogRequests(false, false, "", WithAfterReport(func(*gin.Context, string, error) {
+			calls++
+		})))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.Zero(t, calls)
+	})
+
+	t.Run("does not fire when the async queue drops the report", func(t *testing.T) {
+		captureReports(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		var sent int
+		RollbarError = func(interfaces ...interface{}) {
+			if sent == 0 {
+				close(started)
+				<-release
+			}
+			sent++
+		}
+		var calls int
+		router := newTestRouter(LogRequests(false, false, "",
+			WithAsyncQueue(1),
+			WithAfterReport(func(*gin.Context, string, error) { calls++ }),
+		))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+		<-started
+		for i := 0; i < 4; i++ {
+			performRequest("GET", "/", router)
+		}
+		close(release)
+
+		assert.NoError(t, Shutdown(context.Background()))
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, 2, calls, "the dropped reports do not fire the hook")
+	})
+}
+
+func TestWithReportErrorsAndPanics(t *testing.T) {
+	tests := []struct {
+		name              string
+		reportErrors      bool
+		reportPanics      bool
+		expectedErrors    int
+		expectedCriticals int
+	}{
+		{name: "errors and panics", reportErrors: true, reportPanics: true, expectedErrors: 1, expectedCriticals: 1},
+		{name: "errors only", reportErrors: true, expectedErrors: 1},
+		{name: "panics only", reportPanics: true, expectedCriticals: 1},
+		{name: "nothing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := captureReports(t)
+			middleware := LogRequests(false, false, "", WithReportErrors(tt.reportErrors), WithReportPanics(tt.reportPanics))
+			router := newTestRouter(middleware)
+			router.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("test error"))
+				panic("occurs panic")
+			})
+
+			w := performRequest("GET", "/", router)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.Len(t, rep.errors, tt.expectedErrors)
+			assert.Len(t, rep.criticals, tt.expectedCriticals)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package ginrollbar
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// droppedReports counts the reports dropped because their async queue was full
+var droppedReports atomic.Int64
+
+// queues are the async queues Shutdown drains
+var (
+	queuesMu sync.Mutex
+	queues   []*asyncQueue
+)
+
+// asyncQueue sends reports from a background goroutine, see WithAsyncQueue
+type asyncQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	items  chan func()
+	done   chan struct{}
+}
+
+func newAsyncQueue(size int) *asyncQueue {
+	q := &asyncQueue{
+		items: make(chan func(), size),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+
+	queuesMu.Lock()
+	queues = append(queues, q)
+	queuesMu.Unlock()
+	return q
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	for send := range q.items {
+		q.send(send)
+	}
+}
+
+// send calls a queued report, a panic in it does not stop the queue
+func (q *asyncQueue) send(send func()) {
+	defer func() {
+		_ = recover()
+	}()
+	send()
+}
+
+// enqueue queues a report or drops it when the queue is full, it returns false when dropped.
+// Reports are sent right away once the queue is shut down
+func (q *asyncQueue) enqueue(send func()) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		send()
+		return true
+	}
+	select {
+	case q.items <- send:
+		return true
+	default:
+		droppedReports.Add(1)
+		return false
+	}
+}
+
+func (q *asyncQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+}
+
+// DroppedReports returns the number of reports dropped because their async queue was full
+func DroppedReports() int64 {
+	return droppedReports.Load()
+}
+
+// Shutdown stops the async queues of WithAsyncQueue and waits until their reports are
+// handed to rollbar, or returns the error of ctx. Call Flush afterwards so rollbar sends them
+func Shutdown(ctx context.Context) error {
+	queuesMu.Lock()
+	pending := queues
+	queues = nil
+	queuesMu.Unlock()
+
+	for _, q := range pending {
+		q.close()
+	}
+	for _, q := range pending {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package ginrollbar
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithAsyncQueue(t *testing.T) {
+	t.Run("reports are delivered", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "", WithAsyncQueue(10)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+			panic("occurs panic")
+		})
+
+		performRequest("GET", "/", router)
+
+		assert.NoError(t, Shutdown(context.Background()))
+		assert.Len(t, rep.errors, 1)
+		assert.Len(t, rep.criticals, 1)
+	})
+
+	t.Run("overflow is dropped and Shutdown drains pending reports", func(t *testing.T) {
+		captureReports(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		var sent []string
+		RollbarError = func(interfaces ...interface{}) {
+			if len(sent) == 0 {
+				close(started)
+				<-release
+			}
+			sent = append(sent, interfaces[0].(error).Error())
+		}
+		router := newTestRouter(LogRequests(false, false, "", WithAsyncQueue(1)))
+		router.GET("/:id", func(c *gin.Context) {
+			_ = c.Error(errors.New(c.Param("id")))
+		})
+		dropped := DroppedReports()
+
+		performRequest("GET", "/1", router)
+		<-started
+		performRequest("GET", "/2", router)
+		performRequest("GET", "/3", router)
+		close(release)
+
+		assert.NoError(t, Shutdown(context.Background()))
+		assert.Equal(t, []string{"1", "2"}, sent)
+		assert.Equal(t, dropped+1, DroppedReports())
+	})
+
+	t.Run("Shutdown gives up with its context", func(t *testing.T) {
+		captureReports(t)
+		release := make(chan struct{})
+		defer close(release)
+		RollbarError = func(interfaces ...interface{}) {
+			<-release
+		}
+		router := newTestRouter(LogRequests(false, false, "", WithAsyncQueue(1)))
+		router.GET("/", func(c *gin.Context) {
+			_ = c.Error(errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, Shutdown(ctx), context.DeadlineExceeded)
+	})
+}
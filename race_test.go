@@ -0,0 +1,6 @@
+//go:build race
+
+package ginrollbar
+
+// raceEnabled skips the allocation checks, the race detector allocates on its own
+const raceEnabled = true
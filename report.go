@@ -0,0 +1,65 @@
+package ginrollbar
+
+import (
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// ReportError reports err with the same extra data as the error path of the middleware,
+// e.g. from a goroutine spawned by a handler, in which case pass it Copy(c).
+// err is reported as a private gin error unless it already is a *gin.Error
+func ReportError(c *gin.Context, err error) {
+	cfg, state := stateFrom(c)
+	if cfg.skipReport(c) {
+		return
+	}
+
+	var item *gin.Error
+	if !errors.As(err, &item) {
+		item = &gin.Error{Err: err, Type: gin.ErrorTypePrivate}
+	}
+	cfg.reportError(c, state, item, cfg.extraData(c, state))
+	cfg.waitIfSynchronous()
+}
+
+// ReportPanic reports a recovered panic value with the same extra data and stack skip as the
+// panic path of the middleware, without re-panicking. Call it from the handler's own recover
+func ReportPanic(c *gin.Context, recovered interface{}) {
+	cfg, state := stateFrom(c)
+	if cfg.skipReport(c) {
+		return
+	}
+
+	var stack string
+	if cfg.captureStack {
+		stack = string(debug.Stack())
+	}
+
+	// report is called from here rather than through reportPanic, so the stack skip counts
+	// the same frames as in the middleware and the stack starts at the caller's recover
+	panicErr, extraPanicData, ok := cfg.panicReport(c, state, recovered, stack)
+	if !ok {
+		return
+	}
+	cfg.report(c, "panic", cfg.panicLevel(c, recovered), panicErr, extraPanicData, cfg.panicStackSkip())
+	cfg.waitIfSynchronous()
+}
+
+// Go runs fn in a goroutine with a copy of c, a panic in fn is reported like ReportPanic with
+// the extra data of the request and then swallowed, unless WithGoRepanic is set
+func Go(c *gin.Context, fn func()) {
+	cp := Copy(c)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ReportPanic(cp, r)
+				if cfg, _ := stateFrom(cp); cfg.goRepanic {
+					panic(r)
+				}
+			}
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,148 @@
+package ginrollbar
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportError(t *testing.T) {
+	t.Run("extra data matches the middleware error path", func(t *testing.T) {
+		rep := captureReports(t)
+		router := newTestRouter(LogRequests(false, false, "request_id", WithRequestIDFromContext(true), WithClientIP(true)))
+		router.GET("/users/:id", func(c *gin.Context) {
+			c.Set("request_id", "abc")
+			done := make(chan struct{})
+			cp := c.Copy()
+			go func() {
+				defer close(done)
+				ReportError(cp, errors.New("background error"))
+			}()
+			<-done
+			c.AbortWithError(http.StatusBadRequest, errors.New("test error")) //nolint:errcheck
+		})
+
+		performRequest("GET", "/users/42", router)
+
+		if !assert.Len(t, rep.errors, 2) {
+			return
+		}
+		assert.Equal(t, "background error", rep.errors[0][0].(error).Error())
+		assert.Equal(t, "test error", rep.errors[1][0].(error).Error())
+
+		helper, middleware := extraDataOf(t, rep.errors[0]), extraDataOf(t, rep.errors[1])
+		assert.Equal(t, keysOf(middleware), keysOf(helper))
+		for _, key := range []string{"endpoint", "method", "request_id", "ip", "meta"} {
+			assert.Equal(t, middleware[key], helper[key], key)
+		}
+	})
+
+	t.Run("default settings are used outside of the middleware", func(t *testing.T) {
+		rep := captureReports(t)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			ReportError(c, errors.New("test error"))
+		})
+
+		performRequest("GET", "/", router)
+
+		if assert.Len(t, rep.errors, 1) {
+			extraData := extraDataOf(t, rep.errors[0])
+			assert.Equal(t, "/", extraData["endpoint"])
+			assert.NotContains(t, extraData, "duration_ms")
+		}
+	})
+}
+
+func keysOf(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReportPanic(t *testing.T) {
+	rep := captureReports(t)
+	router := newTestRouter(LogRequests(false, false, "", WithCaptureStack(true)))
+	router.GET("/recovered", func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				ReportPanic(c, r)
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		panic("occurs panic")
+	})
+	router.GET("/middleware", func(c *gin.Context) {
+		panic("occurs panic")
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/recovered", router).Code)
+	assert.Equal(t, http.StatusInternalServerError, performRequest("GET", "/middleware", router).Code)
+
+	if !assert.Len(t, rep.criticals, 2) {
+		return
+	}
+	helper, middleware := rep.criticals[0], rep.criticals[1]
+	assert.Len(t, helper, len(middleware))
+	assert.Equal(t, "occurs panic", helper[0].(error).Error())
+	assert.IsType(t, &http.Request{}, helper[1])
+	assert.Equal(t, middleware[2], helper[2], "stack skip")
+
+	helperData, middlewareData := extraDataOf(t, helper), extraDataOf(t, middleware)
+	assert.Equal(t, keysOf(middlewareData), keysOf(helperData))
+	assert.Contains(t, helperData["stack"], "ginrollbar/v2.TestReportPanic")
+}
+
+func TestGo(t *testing.T) {
+	rep := captureReports(t)
+	done := make(chan struct{})
+	router := newTestRouter(LogRequests(false, false, "", WithReportCounter(func(string) {
+		close(done)
+	})))
+	router.GET("/users/:id", func(c *gin.Context) {
+		Go(c, func() {
+			panic("background panic")
+		})
+	})
+
+	w := performRequest("GET", "/users/42", router)
+	<-done
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, rep.criticals, 1) {
+		assert.Equal(t, "background panic", rep.criticals[0][0].(error).Error())
+		extraData := extraDataOf(t, rep.criticals[0])
+		assert.Equal(t, "/users/42", extraData["endpoint"])
+	}
+}
+
+func TestCopy(t *testing.T) {
+	rep := captureReports(t)
+	copies := make(chan [2]*gin.Context, 1)
+	router := newTestRouter(LogRequests(false, false, "", WithClientIP(true)))
+	router.GET("/users/:id", func(c *gin.Context) {
+		copies <- [2]*gin.Context{Copy(c), c.Copy()}
+	})
+
+	performRequest("GET", "/users/42", router)
+	cp := <-copies
+	ReportError(cp[0], errors.New("with state"))
+	ReportError(cp[1], errors.New("without state"))
+
+	if !assert.Len(t, rep.errors, 2) {
+		return
+	}
+	withState, withoutState := extraDataOf(t, rep.errors[0]), extraDataOf(t, rep.errors[1])
+	assert.Contains(t, withState, "duration_ms")
+	assert.Contains(t, withState, "ip")
+	assert.NotContains(t, withoutState, "duration_ms", "c.Copy() finds the state only during the request")
+	assert.NotContains(t, withoutState, "ip")
+}
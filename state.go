@@ -0,0 +1,153 @@
+package ginrollbar
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stateKey is the gin context key holding the requestState in the contexts returned by Copy
+const stateKey = "ginrollbar.state"
+
+// requestState is what the middleware captures before calling the handlers
+type requestState struct {
+	cfg      *config
+	start    time.Time
+	body     []byte
+	bindBody *bodyRecorder
+}
+
+// activeRequests holds the state of the requests being handled by the middleware. Storing it
+// in the gin context keys would allocate their map on every request, gin resets them each time
+var activeRequests registry
+
+// registryShardBits sets the number of shards, which spread the requests over locks so
+// concurrent requests seldom contend
+const registryShardBits = 6
+
+// registry maps the gin contexts and the http requests being handled to their state,
+// the request lets the contexts returned by c.Copy() find the state of the original one
+type registry struct {
+	shards [1 << registryShardBits]registryShard
+}
+
+type registryShard struct {
+	mu       sync.Mutex
+	contexts map[*gin.Context]requestState
+	requests map[*http.Request]requestState
+}
+
+// shard picks the shard of a pointer with a multiplicative hash of its address
+func (r *registry) shard(p unsafe.Pointer) *registryShard {
+	return &r.shards[uint64(uintptr(p))*0x9E3779B97F4A7C15>>(64-registryShardBits)]
+}
+
+// add registers the state of c and of its request, which may be nil
+func (r *registry) add(c *gin.Context, req *http.Request, state requestState) {
+	shard := r.shard(unsafe.Pointer(c))
+	shard.mu.Lock()
+	if shard.contexts == nil {
+		shard.contexts = make(map[*gin.Context]requestState)
+	}
+	shard.contexts[c] = state
+	shard.mu.Unlock()
+
+	if req == nil {
+		return
+	}
+	shard = r.shard(unsafe.Pointer(req))
+	shard.mu.Lock()
+	if shard.requests == nil {
+		shard.requests = make(map[*http.Request]requestState)
+	}
+	shard.requests[req] = state
+	shard.mu.Unlock()
+}
+
+// remove forgets the state of c and of its request
+func (r *registry) remove(c *gin.Context, req *http.Request) {
+	shard := r.shard(unsafe.Pointer(c))
+	shard.mu.Lock()
+	delete(shard.contexts, c)
+	shard.mu.Unlock()
+
+	if req == nil {
+		return
+	}
+	shard = r.shard(unsafe.Pointer(req))
+	shard.mu.Lock()
+	delete(shard.requests, req)
+	shard.mu.Unlock()
+}
+
+// active tells whether c is being handled by the middleware
+func (r *registry) active(c *gin.Context) bool {
+	shard := r.shard(unsafe.Pointer(c))
+	shard.mu.Lock()
+	_, ok := shard.contexts[c]
+	shard.mu.Unlock()
+	return ok
+}
+
+// lookup returns the state of c, or of its request for a context returned by c.Copy().
+// The body recorded so far is copied, the recorder goes back to its pool with the request
+func (r *registry) lookup(c *gin.Context) (requestState, bool) {
+	shard := r.shard(unsafe.Pointer(c))
+	shard.mu.Lock()
+	state, ok := shard.contexts[c]
+	if ok {
+		state.bindBody = state.bindBody.snapshot()
+	}
+	shard.mu.Unlock()
+	if ok || c.Request == nil {
+		return state, ok
+	}
+
+	shard = r.shard(unsafe.Pointer(c.Request))
+	shard.mu.Lock()
+	state, ok = shard.requests[c.Request]
+	if ok {
+		state.bindBody = state.bindBody.snapshot()
+	}
+	shard.mu.Unlock()
+	return state, ok
+}
+
+// stateFrom returns the settings and state of the middleware handling c, or the default
+// settings when the middleware did not run or the request is over and c is not from Copy
+func stateFrom(c *gin.Context) (*config, *requestState) {
+	if value, ok := c.Get(stateKey); ok {
+		if state, ok := value.(*requestState); ok {
+			return state.cfg, state
+		}
+	}
+	if state, ok := activeRequests.lookup(c); ok {
+		return state.cfg, &state
+	}
+	return newConfig(false, false, ""), nil
+}
+
+// Copy returns c.Copy() carrying the state of the middleware, so ReportError, ReportPanic
+// and Go keep the settings and extra data of the request once it is over.
+// c.Copy() only finds them while the request is being handled
+func Copy(c *gin.Context) *gin.Context {
+	cp := c.Copy()
+	if _, ok := cp.Get(stateKey); !ok {
+		if state, ok := activeRequests.lookup(c); ok {
+			cp.Set(stateKey, &state)
+		}
+	}
+	return cp
+}
+
+// snapshot returns a recorder holding a copy of what r recorded so far
+func (r *bodyRecorder) snapshot() *bodyRecorder {
+	if r == nil {
+		return nil
+	}
+	return &bodyRecorder{maxBytes: r.maxBytes, read: slices.Clone(r.read)}
+}
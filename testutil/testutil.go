@@ -0,0 +1,95 @@
+// Package testutil records the occurrences ginrollbar sends to rollbar so tests can assert
+// on them without reaching the rollbar API
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/neiybor/ginrollbar/v2"
+	"github.com/rollbar/rollbar-go"
+)
+
+// Recorder holds the arguments of every report sent through ginrollbar, by rollbar level
+type Recorder struct {
+	mu      sync.Mutex
+	reports map[string][][]interface{}
+
+	restore func()
+}
+
+// Capture replaces the ginrollbar rollbar functions with recording ones until Restore is
+// called. Captures must not overlap, so tests using it cannot run in parallel
+func Capture() *Recorder {
+	rec := &Recorder{reports: make(map[string][][]interface{})}
+
+	rollbarCritical := ginrollbar.RollbarCritical
+	rollbarError := ginrollbar.RollbarError
+	rollbarWarning := ginrollbar.RollbarWarning
+	rollbarInfo := ginrollbar.RollbarInfo
+	rollbarDebug := ginrollbar.RollbarDebug
+	rollbarCriticalWithContext := ginrollbar.RollbarCriticalWithContext
+	rollbarErrorWithContext := ginrollbar.RollbarErrorWithContext
+	rec.restore = func() {
+		ginrollbar.RollbarCritical = rollbarCritical
+		ginrollbar.RollbarError = rollbarError
+		ginrollbar.RollbarWarning = rollbarWarning
+		ginrollbar.RollbarInfo = rollbarInfo
+		ginrollbar.RollbarDebug = rollbarDebug
+		ginrollbar.RollbarCriticalWithContext = rollbarCriticalWithContext
+		ginrollbar.RollbarErrorWithContext = rollbarErrorWithContext
+	}
+
+	ginrollbar.RollbarCritical = rec.recorder(rollbar.CRIT)
+	ginrollbar.RollbarError = rec.recorder(rollbar.ERR)
+	ginrollbar.RollbarWarning = rec.recorder(rollbar.WARN)
+	ginrollbar.RollbarInfo = rec.recorder(rollbar.INFO)
+	ginrollbar.RollbarDebug = rec.recorder(rollbar.DEBUG)
+	ginrollbar.RollbarCriticalWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		rec.record(rollbar.CRIT, append(interfaces, ctx))
+	}
+	ginrollbar.RollbarErrorWithContext = func(ctx context.Context, interfaces ...interface{}) {
+		rec.record(rollbar.ERR, append(interfaces, ctx))
+	}
+	return rec
+}
+
+// Reports returns the arguments of the reports at the given rollbar level, e.g. rollbar.WARN,
+// in order
+func (r *Recorder) Reports(level string) [][]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]interface{}(nil), r.reports[level]...)
+}
+
+// Errors returns the arguments of the error reports, in order
+func (r *Recorder) Errors() [][]interface{} {
+	return r.Reports(rollbar.ERR)
+}
+
+// Criticals returns the arguments of the critical reports, in order
+func (r *Recorder) Criticals() [][]interface{} {
+	return r.Reports(rollbar.CRIT)
+}
+
+// Restore puts the original rollbar functions back, it is safe to call more than once
+func (r *Recorder) Restore() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restore != nil {
+		r.restore()
+		r.restore = nil
+	}
+}
+
+func (r *Recorder) recorder(level string) func(interfaces ...interface{}) {
+	return func(interfaces ...interface{}) {
+		r.record(level, interfaces)
+	}
+}
+
+func (r *Recorder) record(level string, interfaces []interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports[level] = append(r.reports[level], interfaces)
+}
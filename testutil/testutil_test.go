@@ -0,0 +1,82 @@
+package testutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/neiybor/ginrollbar/v2"
+	"github.com/rollbar/rollbar-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(ginrollbar.LogRequests(false, false, "", ginrollbar.WithRecover(true)))
+	router.GET("/error", func(c *gin.Context) {
+		_ = c.Error(errors.New("test error"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("occurs panic")
+	})
+	return router
+}
+
+func TestCapture(t *testing.T) {
+	rec := Capture()
+	defer rec.Restore()
+	router := newRouter()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if assert.Len(t, rec.Errors(), 1) {
+		assert.EqualError(t, rec.Errors()[0][0].(error), "test error")
+	}
+	if assert.Len(t, rec.Criticals(), 1) {
+		assert.EqualError(t, rec.Criticals()[0][0].(error), "occurs panic")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	rollbarError := ginrollbar.RollbarError
+	defer func() { ginrollbar.RollbarError = rollbarError }()
+	var original int
+	ginrollbar.RollbarError = func(interfaces ...interface{}) {
+		original++
+	}
+
+	rec := Capture()
+	ginrollbar.RollbarError(errors.New("captured error"))
+	rec.Restore()
+	rec.Restore()
+	ginrollbar.RollbarError(errors.New("restored error"))
+
+	assert.Len(t, rec.Errors(), 1)
+	assert.Equal(t, 1, original)
+}
+
+func TestCaptureLevels(t *testing.T) {
+	rec := Capture()
+	defer rec.Restore()
+	router := gin.New()
+	router.Use(ginrollbar.LogRequests(false, false, "", ginrollbar.WithErrorLevelFunc(func(err *gin.Error) string {
+		return err.Error()
+	})))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New(rollbar.WARN))
+		_ = c.Error(errors.New(rollbar.INFO))
+		_ = c.Error(errors.New(rollbar.DEBUG))
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for _, level := range []string{rollbar.WARN, rollbar.INFO, rollbar.DEBUG} {
+		if assert.Len(t, rec.Reports(level), 1, level) {
+			assert.EqualError(t, rec.Reports(level)[0][0].(error), level)
+		}
+	}
+	assert.Empty(t, rec.Errors())
+}
@@ -0,0 +1,22 @@
+package ginrollbar
+
+// liftedFields are moved from the custom data to the top level of the rollbar payload
+var liftedFields = []string{"fingerprint", "title", "environment", "code_version"}
+
+// Transform moves the fields rollbar only honours at the top level of the payload, such as
+// "fingerprint", "title", "environment" and "code_version", out of the custom data built by
+// the middleware. Install it with:
+//
+//	rollbar.SetTransform(ginrollbar.Transform)
+func Transform(data map[string]interface{}) {
+	custom, ok := data["custom"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, field := range liftedFields {
+		if value, ok := custom[field]; ok {
+			data[field] = value
+			delete(custom, field)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ginrollbar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransform(t *testing.T) {
+	data := map[string]interface{}{
+		"level": "error",
+		"custom": map[string]interface{}{
+			"endpoint":    "/",
+			"fingerprint": "abc",
+			"title":       "custom title",
+			"environment": "admin",
+		},
+	}
+
+	Transform(data)
+
+	assert.Equal(t, map[string]interface{}{
+		"level":       "error",
+		"fingerprint": "abc",
+		"title":       "custom title",
+		"environment": "admin",
+		"custom": map[string]interface{}{
+			"endpoint": "/",
+		},
+	}, data)
+
+	assert.NotPanics(t, func() {
+		Transform(map[string]interface{}{"level": "error"})
+	})
+}
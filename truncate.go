@@ -0,0 +1,62 @@
+package ginrollbar
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+)
+
+// truncatedFirst are dropped before the other fields when the extra data is too large
+var truncatedFirst = []string{"body", "headers"}
+
+// truncateExtraData drops fields of extraData until its JSON encoding fits in maxBytes,
+// the body first, then the headers, then the largest fields. A truncated copy carries
+// "truncated": true
+func truncateExtraData(extraData map[string]interface{}, maxBytes int) map[string]interface{} {
+	sizes := make(map[string]int, len(extraData))
+	total := len("{}")
+	for key, value := range extraData {
+		sizes[key] = fieldSize(key, value)
+		total += sizes[key]
+	}
+	if total <= maxBytes {
+		return extraData
+	}
+
+	truncated := maps.Clone(extraData)
+	truncated["truncated"] = true
+	total += fieldSize("truncated", true)
+	for total > maxBytes && len(sizes) > 0 {
+		key := largestField(sizes)
+		total -= sizes[key]
+		delete(sizes, key)
+		delete(truncated, key)
+	}
+	return truncated
+}
+
+// fieldSize is the size of a field in the JSON encoding of the extra data, values that
+// cannot be encoded count as their string representation
+func fieldSize(key string, value interface{}) int {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return len(key) + len(`"":,`) + len(encoded)
+}
+
+// largestField returns the next field to drop
+func largestField(sizes map[string]int) string {
+	for _, key := range truncatedFirst {
+		if _, ok := sizes[key]; ok {
+			return key
+		}
+	}
+	largest := ""
+	for key, size := range sizes {
+		if largest == "" || size > sizes[largest] || size == sizes[largest] && key < largest {
+			largest = key
+		}
+	}
+	return largest
+}
@@ -0,0 +1,46 @@
+package ginrollbar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateExtraData(t *testing.T) {
+	extraData := map[string]interface{}{
+		"endpoint": "/",
+		"body":     strings.Repeat("a", 50),
+		"headers":  map[string]string{"Accept": "text/plain"},
+		"large":    strings.Repeat("b", 100),
+		"custom":   func() {},
+	}
+
+	tests := []struct {
+		name         string
+		maxBytes     int
+		expectedKeys []string
+	}{
+		{
+			name:         "fitting data is untouched",
+			maxBytes:     1000,
+			expectedKeys: []string{"body", "custom", "endpoint", "headers", "large"},
+		},
+		{
+			name:         "body goes first",
+			maxBytes:     220,
+			expectedKeys: []string{"custom", "endpoint", "headers", "large", "truncated"},
+		},
+		{name: "then the headers", maxBytes: 190, expectedKeys: []string{"custom", "endpoint", "large", "truncated"}},
+		{name: "then the largest fields", maxBytes: 100, expectedKeys: []string{"custom", "endpoint", "truncated"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			truncated := truncateExtraData(extraData, tt.maxBytes)
+
+			assert.Equal(t, tt.expectedKeys, keysOf(truncated))
+			assert.Len(t, extraData, 5)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package ginrollbar
+
+import "strings"
+
+// unknownUserAgent is the browser, os or device of a user agent the parser does not recognize
+const unknownUserAgent = "unknown"
+
+// browserTokens are looked up in order, the first product token found names the browser.
+// Edge and Opera also send the Chrome token and Chrome the Safari one
+var browserTokens = []struct {
+	token string
+	name  string
+}{
+	{token: "Edg/", name: "Edge"},
+	{token: "OPR/", name: "Opera"},
+	{token: "Firefox/", name: "Firefox"},
+	{token: "FxiOS/", name: "Firefox"},
+	{token: "CriOS/", name: "Chrome"},
+	{token: "Chrome/", name: "Chrome"},
+	{token: "Version/", name: "Safari"},
+	{token: "curl/", name: "curl"},
+}
+
+// osTokens are looked up in order, iOS user agents also contain "Mac OS X"
+var osTokens = []struct {
+	token string
+	name  string
+}{
+	{token: "iPhone", name: "iOS"},
+	{token: "iPad", name: "iOS"},
+	{token: "Android", name: "Android"},
+	{token: "Windows", name: "Windows"},
+	{token: "CrOS", name: "ChromeOS"},
+	{token: "Mac OS X", name: "macOS"},
+	{token: "Linux", name: "Linux"},
+}
+
+// parseUserAgent returns the browser with its major version, the operating system and the
+// device class ("desktop", "mobile", "tablet" or "bot") of a User-Agent header
+func parseUserAgent(ua string) (browser, os, device string) {
+	browser, os, device = unknownUserAgent, unknownUserAgent, unknownUserAgent
+	for _, candidate := range browserTokens {
+		if i := strings.Index(ua, candidate.token); i >= 0 {
+			browser = candidate.name
+			if version := majorVersion(ua[i+len(candidate.token):]); version != "" {
+				browser += " " + version
+			}
+			break
+		}
+	}
+	for _, candidate := range osTokens {
+		if strings.Contains(ua, candidate.token) {
+			os = candidate.name
+			break
+		}
+	}
+
+	lower := strings.ToLower(ua)
+	switch {
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider"):
+		device = "bot"
+	case strings.Contains(ua, "iPad") || (os == "Android" && !strings.Contains(ua, "Mobile")):
+		device = "tablet"
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone"):
+		device = "mobile"
+	case os != unknownUserAgent:
+		device = "desktop"
+	}
+	return browser, os, device
+}
+
+// majorVersion returns the leading digits of a product version such as "120.0.6099.109"
+func majorVersion(version string) string {
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		return version
+	}
+	return version[:end]
+}
@@ -0,0 +1,73 @@
+package ginrollbar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name            string
+		ua              string
+		expectedBrowser string
+		expectedOS      string
+		expectedDevice  string
+	}{
+		{
+			name: "chrome on windows",
+			ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+				"Chrome/120.0.6099.109 Safari/537.36",
+			expectedBrowser: "Chrome 120",
+			expectedOS:      "Windows",
+			expectedDevice:  "desktop",
+		},
+		{
+			name: "safari on iphone",
+			ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) " +
+				"Version/17.1 Mobile/15E148 Safari/604.1",
+			expectedBrowser: "Safari 17",
+			expectedOS:      "iOS",
+			expectedDevice:  "mobile",
+		},
+		{
+			name: "edge on macos",
+			ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) " +
+				"Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.77",
+			expectedBrowser: "Edge 120",
+			expectedOS:      "macOS",
+			expectedDevice:  "desktop",
+		},
+		{
+			name:            "firefox on android tablet",
+			ua:              "Mozilla/5.0 (Android 13; Tablet; rv:121.0) Gecko/121.0 Firefox/121.0",
+			expectedBrowser: "Firefox 121",
+			expectedOS:      "Android",
+			expectedDevice:  "tablet",
+		},
+		{
+			name:            "crawler",
+			ua:              "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expectedBrowser: "unknown",
+			expectedOS:      "unknown",
+			expectedDevice:  "bot",
+		},
+		{
+			name:            "garbage",
+			ua:              "%%%garbage%%%",
+			expectedBrowser: "unknown",
+			expectedOS:      "unknown",
+			expectedDevice:  "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			browser, os, device := parseUserAgent(tt.ua)
+
+			assert.Equal(t, tt.expectedBrowser, browser)
+			assert.Equal(t, tt.expectedOS, os)
+			assert.Equal(t, tt.expectedDevice, device)
+		})
+	}
+}
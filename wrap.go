@@ -0,0 +1,135 @@
+package ginrollbar
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WrapHandler reports the panics of a plain net/http handler with the same extra data as the
+// middleware, built from the request alone, and answers them like WithRecover. There are no
+// gin errors outside of gin, so only panics are reported. The handler key holds the name of
+// next and there is no route key. No gin engine is involved: the responses of next are
+// passed through untouched and the client IP is the remote address of the request
+func WrapHandler(next http.Handler, opts ...Option) http.Handler {
+	m := NewMiddleware(true, false, "", append([]Option{WithRecover(true), withWrappedHandler(next)}, opts...)...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &responseWriter{ResponseWriter: w, status: http.StatusOK, size: noWritten}
+		c := &gin.Context{Request: r, Writer: writer}
+		handle(c, m.config(), func(c *gin.Context) {
+			next.ServeHTTP(c.Writer, c.Request)
+		})
+		// a status without body is only sent here, as gin does once its handlers return
+		writer.WriteHeaderNow()
+	})
+}
+
+// withWrappedHandler reports the name of next as the handler instead of the gin one
+func withWrappedHandler(next http.Handler) Option {
+	return func(cfg *config) {
+		cfg.wrappedHandler = handlerName(next)
+	}
+}
+
+// handlerName returns the function name of a http.HandlerFunc, like gin does for its
+// handlers, or the type of any other handler
+func handlerName(handler http.Handler) string {
+	if fn, ok := handler.(http.HandlerFunc); ok {
+		return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+// resolveClientIP returns c.ClientIP(), or the remote address for WrapHandler whose
+// contexts have no gin engine to resolve it
+func (cfg *config) resolveClientIP(c *gin.Context) string {
+	if cfg.wrappedHandler != "" {
+		return c.RemoteIP()
+	}
+	return c.ClientIP()
+}
+
+// noWritten is the size of a response whose header is not written yet, like in gin
+const noWritten = -1
+
+// responseWriter is the gin.ResponseWriter of WrapHandler, it tracks the status and the
+// size of the response like the gin one
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.size < 0 {
+		w.size = 0
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// CloseNotify is part of gin.ResponseWriter, a writer without it never notifies
+func (w *responseWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck // gin.ResponseWriter still requires http.CloseNotifier
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *responseWriter) Pusher() http.Pusher {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher
+	}
+	return nil
+}
+
+// Unwrap lets http.ResponseController reach the writer of next
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,97 @@
+package ginrollbar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapHandler(t *testing.T) {
+	t.Run("panics are reported and answered", func(t *testing.T) {
+		rep := captureReports(t)
+		handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("occurs panic")
+		}), WithCaptureQueryParams(true))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/plain?id=42", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		if assert.Len(t, rep.criticals, 1) {
+			assert.EqualError(t, rep.criticals[0][0].(error), "occurs panic")
+			extraData := extraDataOf(t, rep.criticals[0])
+			assert.Equal(t, "/plain?id=42", extraData["endpoint"])
+			assert.Equal(t, "GET", extraData["method"])
+			assert.Equal(t, map[string]string{"id": "42"}, extraData["query"])
+			assert.Equal(t, "github.com/neiybor/ginrollbar/v2.TestWrapHandler.func1.1", extraData["handler"])
+			assert.NotContains(t, extraData, "route")
+		}
+	})
+
+	t.Run("the status of the handler is reported", func(t *testing.T) {
+		rep := captureReports(t)
+		handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("occurs panic")
+		}), WithClientIP(true))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if assert.Len(t, rep.criticals, 1) {
+			extraData := extraDataOf(t, rep.criticals[0])
+			assert.Equal(t, http.StatusAccepted, extraData["status_code"])
+			assert.Equal(t, "192.0.2.1", extraData["ip"])
+		}
+	})
+
+	t.Run("the handler is named after its type", func(t *testing.T) {
+		rep := captureReports(t)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+			panic("occurs panic")
+		})
+
+		handler := WrapHandler(mux)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/plain", nil))
+
+		if assert.Len(t, rep.criticals, 1) {
+			extraData := extraDataOf(t, rep.criticals[0])
+			assert.Equal(t, "*http.ServeMux", extraData["handler"])
+			assert.NotContains(t, extraData, "route")
+		}
+	})
+
+	t.Run("responses are passed through", func(t *testing.T) {
+		rep := captureReports(t)
+		handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/created" {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("created"))
+			}
+		}))
+
+		for target, expected := range map[string]int{"/created": http.StatusCreated, "/empty": http.StatusOK} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("POST", target, nil))
+			assert.Equal(t, expected, w.Code, target)
+		}
+
+		w := httptest.NewRecorder()
+		WrapHandler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "404 page not found\n", w.Body.String(), "the body of net/http, not the one of gin")
+
+		w = httptest.NewRecorder()
+		WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Empty(t, rep.criticals)
+		assert.Empty(t, rep.errors)
+	})
+}